@@ -7,7 +7,9 @@ require (
 	github.com/shouni/go-http-kit v1.2.1
 	github.com/shouni/go-remote-io v1.2.0
 	github.com/stretchr/testify v1.11.1
+	golang.org/x/image v0.23.0
 	google.golang.org/genai v1.43.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -75,5 +77,4 @@ require (
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20251111163417-95abcf5c77ba // indirect
 	google.golang.org/grpc v1.78.0 // indirect
 	google.golang.org/protobuf v1.36.10 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
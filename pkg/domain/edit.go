@@ -0,0 +1,38 @@
+package domain
+
+// EditMode は画像編集の種類です。
+type EditMode string
+
+const (
+	// EditModeInpaint は画像の一部 (マスク指定時はその範囲) を描き直します。
+	EditModeInpaint EditMode = "inpaint"
+	// EditModeOutpaint は Padding で指定した分だけ画像の外側を描き足します。
+	EditModeOutpaint EditMode = "outpaint"
+	// EditModeBackgroundReplace は人物などの前景を保ったまま背景のみを差し替えます。
+	EditModeBackgroundReplace EditMode = "background_replace"
+)
+
+// Padding はアウトペイント時に拡張する余白 (px) です。
+type Padding struct {
+	Top    int
+	Right  int
+	Bottom int
+	Left   int
+}
+
+// ImageEditRequest は既存画像に対する編集要求です。
+type ImageEditRequest struct {
+	Image          ImageURI  // 編集元の画像
+	Mask           *ImageURI // 編集範囲 (白黒の二値マスク、または透明部分を編集対象とする PNG)
+	Instruction    string    // 編集内容の指示
+	Mode           EditMode  // 省略時は EditModeInpaint。定義外の値はエラー
+	Padding        Padding   // EditModeOutpaint の拡張量
+	SystemPrompt   string
+	NegativePrompt string
+	AspectRatio    string
+	ImageSize      string
+	Seed           *int64
+	CandidateCount int
+
+	GenerationConfig *GenerationConfig // 生成パラメータ・セーフティしきい値 (nil の場合はデフォルト)
+}
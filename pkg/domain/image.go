@@ -1,36 +1,147 @@
 package domain
 
+import "time"
+
 // ImageURI は画像の参照先情報を保持します。
+// 前段の処理で生成済みの画像など、メモリ上のデータは Data に直接指定できます。
 type ImageURI struct {
-	ReferenceURL string // 元の参照先 (GCS, HTTP等)
+	ReferenceURL string // 元の参照先 (GCS, HTTP, file://, data: URI, ローカルパス等)
 	FileAPIURI   string // Gemini File API 上の URI (https://...)
+	Data         []byte // メモリ上の画像データ (ReferenceURL より優先)
+	MimeType     string // Data の MIME タイプ (省略時は内容から判定)
+}
+
+// ReferencePolicy は参照画像が取得・変換できなかった場合の扱いを指定します。
+type ReferencePolicy int
+
+const (
+	// WarnOnly は警告ログを出力し、その参照画像を除外して生成を続行します (デフォルト)。
+	WarnOnly ReferencePolicy = iota
+	// SkipMissing はログを出さずに、その参照画像を除外して生成を続行します。
+	SkipMissing
+	// FailOnMissingReference は生成を中止してエラーを返します。
+	FailOnMissingReference
+)
+
+// SkippedReference は生成に使用されなかった参照画像とその理由です。
+type SkippedReference struct {
+	Image  ImageURI
+	Reason string
+}
+
+// SafetySetting は有害カテゴリごとのブロックしきい値です。
+// 値は Gemini API の列挙値 (例: "HARM_CATEGORY_HARASSMENT", "BLOCK_ONLY_HIGH") をそのまま指定します。
+type SafetySetting struct {
+	Category  string
+	Threshold string
+}
+
+// GenerationConfig はリクエスト単位の生成パラメータです。
+// nil または空の項目はクライアント側のデフォルトが使用されます。
+// SafetySettings 以外の項目は、全ての生成パラメータを送信できるクライアント (generator.GenAIClient) が必要です。
+type GenerationConfig struct {
+	Temperature      *float32
+	TopP             *float32
+	TopK             *float32
+	ResponseMIMEType string // 出力の MIME タイプ (例: "image/png")
+	SafetySettings   []SafetySetting
 }
 
 // ImageGenerationRequest は単一の画像生成要求です。
 type ImageGenerationRequest struct {
-	Prompt         string
-	SystemPrompt   string
-	NegativePrompt string
-	AspectRatio    string
-	ImageSize      string
-	Image          ImageURI
-	Seed           *int64
+	Prompt          string
+	SystemPrompt    string
+	NegativePrompt  string
+	AspectRatio     string
+	ImageSize       string
+	Image           ImageURI
+	Seed            *int64
+	CandidateCount  int             // 生成するバリエーション数 (0 の場合はモデルのデフォルト, 2 以上は ContentModel クライアントが必要)
+	ReferencePolicy ReferencePolicy // 参照画像が取得できない場合の扱い
+
+	Model            string            // 使用するモデル (空の場合はジェネレーターの設定に従う)
+	GenerationConfig *GenerationConfig // 生成パラメータ (nil の場合はデフォルト)
+	CharacterIDs     []string          // 登場キャラクターの ID ("id" または "id:表情")
+	Style            string            // 適用するスタイルプリセット名 (空の場合は適用しない)
+	Template         string            // プロンプトテンプレート名 (指定時は描画結果を Prompt の前に追加)
+	TemplateVars     map[string]any    // テンプレートに渡す変数 (キャラクター名・シーン・カメラアングル等)
 }
 
 // ImagePageRequest は漫画1ページの一括生成要求です。
 type ImagePageRequest struct {
-	Prompt         string
-	SystemPrompt   string
-	NegativePrompt string
-	AspectRatio    string
-	ImageSize      string
-	Images         []ImageURI
-	Seed           *int64
+	Prompt          string
+	SystemPrompt    string
+	NegativePrompt  string
+	AspectRatio     string
+	ImageSize       string
+	Images          []ImageURI
+	Seed            *int64
+	CandidateCount  int             // 生成するバリエーション数 (0 の場合はモデルのデフォルト, 2 以上は ContentModel クライアントが必要)
+	ReferencePolicy ReferencePolicy // 参照画像が取得できない場合の扱い
+	PayloadBudget   int             // インライン参照画像の合計サイズ上限 (バイト, 0 はデフォルト, 負数は無制限)
+
+	Model            string            // 使用するモデル (空の場合はジェネレーターの設定に従う)
+	GenerationConfig *GenerationConfig // 生成パラメータ (nil の場合はデフォルト)
+	CharacterIDs     []string          // 登場キャラクターの ID ("id" または "id:表情")
+	Style            string            // 適用するスタイルプリセット名 (空の場合は適用しない)
+	Template         string            // プロンプトテンプレート名 (指定時は描画結果を Prompt の前に追加)
+	TemplateVars     map[string]any    // テンプレートに渡す変数 (キャラクター名・シーン・カメラアングル等)
+}
+
+// GeneratedImage は生成された1枚分の画像データです。
+type GeneratedImage struct {
+	Data           []byte
+	MimeType       string
+	CandidateIndex int // レスポンス内の候補 (Candidate) の番号
+}
+
+// SafetyRating はカテゴリごとの安全性評価です。
+type SafetyRating struct {
+	Category    string // 例: HARM_CATEGORY_DANGEROUS_CONTENT
+	Probability string // 例: NEGLIGIBLE, LOW, MEDIUM, HIGH
+	Blocked     bool
+}
+
+// SafetyBlockReport はセーフティフィルターによるブロックの詳細です。モデレーションでの確認に使用します。
+type SafetyBlockReport struct {
+	FinishReason string         // 候補がブロックされた場合の FinishReason (例: "SAFETY", "IMAGE_SAFETY")
+	BlockReason  string         // プロンプト自体がブロックされた場合の理由
+	Message      string         // API が返した補足メッセージ
+	Triggered    []SafetyRating // ブロックの原因と判定されたカテゴリ
+	Ratings      []SafetyRating // 全カテゴリの評価
+}
+
+// TokenUsage は1回のリクエストで消費したトークン数です。
+type TokenUsage struct {
+	PromptTokens     int32
+	CandidatesTokens int32
+	ThoughtsTokens   int32
+	TotalTokens      int32
 }
 
 // ImageResponse は生成された画像データとそのメタデータです。
+// Data と MimeType は Images の先頭要素と同じ内容を保持します。
 type ImageResponse struct {
-	Data     []byte
-	MimeType string
-	UsedSeed int64
+	Data          []byte
+	MimeType      string
+	UsedSeed      int64
+	Images        []GeneratedImage
+	Text          string         // モデルが画像と併せて返したテキスト (解説など)
+	SafetyRatings []SafetyRating // 画像を返した候補の安全性評価
+	Usage         *TokenUsage    // トークン消費量 (レスポンスに含まれない場合は nil)
+	Model         string         // リクエストに使用したモデル名
+	ModelVersion  string         // レスポンスが報告したモデルのバージョン
+
+	UsedReferences    []ImageURI         // 実際にリクエストへ含めた参照画像
+	SkippedReferences []SkippedReference // 取得できずに除外した参照画像
+
+	Attempts []GenerationAttempt // API 呼び出しの試行履歴 (リトライした場合は複数件)
+}
+
+// GenerationAttempt は API 呼び出し1回分の記録です。
+type GenerationAttempt struct {
+	Number int           // 1 始まりの試行番号
+	Seed   int64         // この試行で使用したシード (未指定の場合は 0)
+	Delay  time.Duration // この試行の前に待機した時間
+	Err    string        // 失敗した場合のエラー内容 (成功時は空)
 }
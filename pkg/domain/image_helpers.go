@@ -1,5 +1,10 @@
 package domain
 
+import (
+	"fmt"
+	"io"
+)
+
 // DereferenceSeed は、int64のポインタを安全にデリファレンスします。
 // ポインタがnilの場合は0を返します。
 func DereferenceSeed(seed *int64) int64 {
@@ -8,3 +13,44 @@ func DereferenceSeed(seed *int64) int64 {
 	}
 	return *seed
 }
+
+// ToPageRequest は単一パネルの要求を、参照画像1枚のページ要求に変換します。
+// 参照画像が未指定の場合は Images を空にします。
+func (r ImageGenerationRequest) ToPageRequest() ImagePageRequest {
+	var images []ImageURI
+	if !r.Image.IsEmpty() {
+		images = []ImageURI{r.Image}
+	}
+	return ImagePageRequest{
+		Prompt:          r.Prompt,
+		SystemPrompt:    r.SystemPrompt,
+		NegativePrompt:  r.NegativePrompt,
+		AspectRatio:     r.AspectRatio,
+		ImageSize:       r.ImageSize,
+		Images:          images,
+		Seed:            r.Seed,
+		CandidateCount:  r.CandidateCount,
+		ReferencePolicy: r.ReferencePolicy,
+
+		Model:            r.Model,
+		GenerationConfig: r.GenerationConfig,
+		CharacterIDs:     r.CharacterIDs,
+		Style:            r.Style,
+		Template:         r.Template,
+		TemplateVars:     r.TemplateVars,
+	}
+}
+
+// IsEmpty は参照先とデータのいずれも指定されていない場合に true を返します。
+func (u ImageURI) IsEmpty() bool {
+	return u.ReferenceURL == "" && u.FileAPIURI == "" && len(u.Data) == 0
+}
+
+// ImageURIFromReader は io.Reader から画像データを読み込み、メモリ上の参照画像として返します。
+func ImageURIFromReader(r io.Reader, mimeType string) (ImageURI, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ImageURI{}, fmt.Errorf("failed to read image data: %w", err)
+	}
+	return ImageURI{Data: data, MimeType: mimeType}, nil
+}
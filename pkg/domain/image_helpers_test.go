@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -18,3 +19,44 @@ func TestSeedUtils(t *testing.T) {
 		}
 	})
 }
+
+func TestImageGenerationRequest_ToPageRequest(t *testing.T) {
+	t.Run("参照画像がある場合は Images に1件含まれるのだ", func(t *testing.T) {
+		req := ImageGenerationRequest{
+			Prompt:          "panel",
+			Image:           ImageURI{ReferenceURL: "gs://bucket/a.png"},
+			ReferencePolicy: FailOnMissingReference,
+			Model:           "model-b",
+			Style:           "4-koma",
+		}
+		page := req.ToPageRequest()
+		if len(page.Images) != 1 || page.Images[0].ReferenceURL != "gs://bucket/a.png" {
+			t.Errorf("unexpected Images: %+v", page.Images)
+		}
+		if page.Prompt != "panel" || page.ReferencePolicy != FailOnMissingReference || page.Model != "model-b" || page.Style != "4-koma" {
+			t.Errorf("fields were not copied: %+v", page)
+		}
+	})
+
+	t.Run("参照画像が空の場合は Images も空になるのだ", func(t *testing.T) {
+		page := ImageGenerationRequest{Prompt: "panel"}.ToPageRequest()
+		if len(page.Images) != 0 {
+			t.Errorf("expected no images, got %+v", page.Images)
+		}
+	})
+}
+
+func TestImageURIFromReader(t *testing.T) {
+	t.Run("Reader の内容が Data に格納されるのだ", func(t *testing.T) {
+		uri, err := ImageURIFromReader(strings.NewReader("png-bytes"), "image/png")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(uri.Data) != "png-bytes" || uri.MimeType != "image/png" {
+			t.Errorf("unexpected ImageURI: %+v", uri)
+		}
+		if uri.IsEmpty() {
+			t.Error("ImageURI with data should not be empty")
+		}
+	})
+}
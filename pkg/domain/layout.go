@@ -0,0 +1,163 @@
+package domain
+
+import (
+	"fmt"
+	"image"
+	"slices"
+	"sort"
+)
+
+// ReadingOrder はページ内のコマを読む方向です。
+type ReadingOrder int
+
+const (
+	// ReadingOrderRTL は右上から左下へ読む日本の漫画の順序です。(デフォルト)
+	ReadingOrderRTL ReadingOrder = iota
+	// ReadingOrderLTR は左上から右下へ読む順序です。
+	ReadingOrderLTR
+)
+
+// PanelRect はページ上のコマの位置とサイズ (px) です。座標は仕上がり線 (裁ち落としを除く) の左上が原点です。
+type PanelRect struct {
+	X, Y, Width, Height int
+}
+
+// Rect は image.Rectangle に変換します。
+func (r PanelRect) Rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// PageLayout はコマ割りを合成する際のページのレイアウトです。
+type PageLayout struct {
+	Width        int          // 仕上がりサイズの幅 (px)
+	Height       int          // 仕上がりサイズの高さ (px)
+	Bleed        int          // 仕上がり線の外側に付ける裁ち落とし幅 (px)
+	Gutter       int          // コマ間の余白 (px)。各コマの四辺を Gutter/2 ずつ内側に縮めます
+	BorderWidth  int          // コマの枠線の太さ (px)。0 の場合は描画しない
+	Panels       []PanelRect  // コマの配置 (順不同)
+	ReadingOrder ReadingOrder // 読み順
+}
+
+// Validate はレイアウトが合成可能かどうかを検証します。
+func (l PageLayout) Validate() error {
+	if l.Width <= 0 || l.Height <= 0 {
+		return fmt.Errorf("page size must be positive: %dx%d", l.Width, l.Height)
+	}
+	if l.Bleed < 0 || l.Gutter < 0 || l.BorderWidth < 0 {
+		return fmt.Errorf("bleed, gutter and border width must not be negative")
+	}
+	if len(l.Panels) == 0 {
+		return fmt.Errorf("layout has no panels")
+	}
+
+	page := image.Rect(0, 0, l.Width, l.Height)
+	for i, p := range l.Panels {
+		if !p.Rect().In(page) {
+			return fmt.Errorf("panel %d %v is outside the page", i, p.Rect())
+		}
+		if p.Width-l.Gutter <= 0 || p.Height-l.Gutter <= 0 {
+			return fmt.Errorf("panel %d is too small for gutter %d", i, l.Gutter)
+		}
+		for j := range i {
+			if p.Rect().Overlaps(l.Panels[j].Rect()) {
+				return fmt.Errorf("panels %d and %d overlap", j, i)
+			}
+		}
+	}
+	return nil
+}
+
+// OrderedPanels はコマを読み順に並べて返します。
+// 縦方向に重なるコマを同じ段にまとめて上の段から読み、段の中は横方向に重なるコマを同じ列にまとめて
+// RTL なら右の列から、LTR なら左の列から読みます。列の中も同じ規則で再帰的に並べるため、
+// 複数の段にまたがる縦長のコマがある場合は、その隣の列のコマを上から順に読んでから次の列に進みます。
+func (l PageLayout) OrderedPanels() []PanelRect {
+	return orderPanels(append([]PanelRect(nil), l.Panels...), l.ReadingOrder)
+}
+
+// orderPanels は panels を段・列に分割しながら読み順に並べます。
+// どちらの方向にも分割できない配置 (風車型など) は、上端・読み方向の順に並べます。
+func orderPanels(panels []PanelRect, order ReadingOrder) []PanelRect {
+	if len(panels) <= 1 {
+		return panels
+	}
+
+	rows := groupOverlapping(panels, func(p PanelRect) (int, int) { return p.Y, p.Y + p.Height })
+	if len(rows) > 1 {
+		var out []PanelRect
+		for _, row := range rows {
+			out = append(out, orderPanels(row, order)...)
+		}
+		return out
+	}
+
+	cols := groupOverlapping(panels, func(p PanelRect) (int, int) { return p.X, p.X + p.Width })
+	if len(cols) > 1 {
+		if order != ReadingOrderLTR {
+			slices.Reverse(cols)
+		}
+		var out []PanelRect
+		for _, col := range cols {
+			out = append(out, orderPanels(col, order)...)
+		}
+		return out
+	}
+
+	sort.SliceStable(panels, func(i, j int) bool {
+		a, b := panels[i], panels[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		if order == ReadingOrderLTR {
+			return a.X < b.X
+		}
+		return a.X > b.X
+	})
+	return panels
+}
+
+// groupOverlapping は span が返す区間で panels を並べ、区間が重なり合うコマを1つのグループにまとめます。
+// グループは区間の開始位置の昇順で返します。接しているだけのコマは別のグループになります。
+func groupOverlapping(panels []PanelRect, span func(PanelRect) (int, int)) [][]PanelRect {
+	sorted := append([]PanelRect(nil), panels...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, _ := span(sorted[i])
+		b, _ := span(sorted[j])
+		return a < b
+	})
+
+	var groups [][]PanelRect
+	end := 0
+	for i, p := range sorted {
+		start, stop := span(p)
+		if i == 0 || start >= end {
+			groups = append(groups, nil)
+			end = stop
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], p)
+		end = max(end, stop)
+	}
+	return groups
+}
+
+// PanelArea はガター分を除いたコマの描画領域を、裁ち落としを含むキャンバス上の座標で返します。
+// 裁ち落としがある場合、仕上がり線に接する辺はガターで縮めずにキャンバスの端まで広げます (裁ち切りのコマ)。
+func (l PageLayout) PanelArea(p PanelRect) image.Rectangle {
+	inset := l.Gutter / 2
+	area := p.Rect().Inset(inset).Add(image.Pt(l.Bleed, l.Bleed))
+	if l.Bleed > 0 {
+		if p.X == 0 {
+			area.Min.X = 0
+		}
+		if p.Y == 0 {
+			area.Min.Y = 0
+		}
+		if p.X+p.Width == l.Width {
+			area.Max.X = l.Width + 2*l.Bleed
+		}
+		if p.Y+p.Height == l.Height {
+			area.Max.Y = l.Height + 2*l.Bleed
+		}
+	}
+	return area
+}
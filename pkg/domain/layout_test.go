@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"image"
+	"testing"
+)
+
+// testLayout は右に縦長のコマ、左に2段のコマを持つ 1000x1400 のページです。
+func testLayout(order ReadingOrder) PageLayout {
+	return PageLayout{
+		Width:  1000,
+		Height: 1400,
+		Bleed:  30,
+		Gutter: 20,
+		Panels: []PanelRect{
+			{X: 0, Y: 700, Width: 500, Height: 700},  // 左下
+			{X: 500, Y: 0, Width: 500, Height: 1400}, // 右 (縦長)
+			{X: 0, Y: 0, Width: 500, Height: 700},    // 左上
+		},
+		ReadingOrder: order,
+	}
+}
+
+func TestPageLayout_OrderedPanels(t *testing.T) {
+	t.Run("RTL では右上のコマから読むのだ", func(t *testing.T) {
+		got := testLayout(ReadingOrderRTL).OrderedPanels()
+		want := []image.Point{{500, 0}, {0, 0}, {0, 700}}
+		for i, p := range got {
+			if (image.Point{p.X, p.Y}) != want[i] {
+				t.Errorf("panel %d: got (%d,%d), want %v", i, p.X, p.Y, want[i])
+			}
+		}
+	})
+
+	t.Run("LTR では左の列を上から読んでから縦長のコマに進むのだ", func(t *testing.T) {
+		got := testLayout(ReadingOrderLTR).OrderedPanels()
+		want := []image.Point{{0, 0}, {0, 700}, {500, 0}}
+		for i, p := range got {
+			if (image.Point{p.X, p.Y}) != want[i] {
+				t.Errorf("panel %d: got (%d,%d), want %v", i, p.X, p.Y, want[i])
+			}
+		}
+	})
+
+	t.Run("上端が少しずれたコマも同じ段として読むのだ", func(t *testing.T) {
+		l := PageLayout{Width: 1000, Height: 1400, Panels: []PanelRect{
+			{X: 0, Y: 20, Width: 500, Height: 680},   // 左上 (少し下がっている)
+			{X: 0, Y: 700, Width: 1000, Height: 700}, // 下段
+			{X: 500, Y: 0, Width: 500, Height: 700},  // 右上
+		}}
+		got := l.OrderedPanels()
+		want := []image.Point{{500, 0}, {0, 20}, {0, 700}}
+		for i, p := range got {
+			if (image.Point{p.X, p.Y}) != want[i] {
+				t.Errorf("panel %d: got (%d,%d), want %v", i, p.X, p.Y, want[i])
+			}
+		}
+	})
+}
+
+func TestPageLayout_PanelArea(t *testing.T) {
+	l := testLayout(ReadingOrderRTL)
+
+	t.Run("仕上がり線に接する辺は裁ち落としの端まで広げるのだ", func(t *testing.T) {
+		got := l.PanelArea(PanelRect{X: 0, Y: 0, Width: 500, Height: 700})
+		if want := image.Rect(0, 0, 520, 720); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		got = l.PanelArea(PanelRect{X: 500, Y: 0, Width: 500, Height: 1400})
+		if want := image.Rect(540, 0, 1060, 1460); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("内側のコマはガターの半分だけ縮めるのだ", func(t *testing.T) {
+		got := l.PanelArea(PanelRect{X: 200, Y: 300, Width: 400, Height: 400})
+		if want := image.Rect(240, 340, 620, 720); got != want {
+			t.Errorf("got %v, want %v (gutter/2 inset, shifted by bleed)", got, want)
+		}
+	})
+
+	t.Run("裁ち落としがない場合は全ての辺を縮めるのだ", func(t *testing.T) {
+		l.Bleed = 0
+		got := l.PanelArea(PanelRect{X: 0, Y: 0, Width: 500, Height: 700})
+		if want := image.Rect(10, 10, 490, 690); got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestPageLayout_Validate(t *testing.T) {
+	if err := testLayout(ReadingOrderRTL).Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*PageLayout)
+	}{
+		{"ページサイズが 0 の場合はエラーなのだ", func(l *PageLayout) { l.Width = 0 }},
+		{"コマがない場合はエラーなのだ", func(l *PageLayout) { l.Panels = nil }},
+		{"ページからはみ出すコマはエラーなのだ", func(l *PageLayout) { l.Panels[1].Width = 600 }},
+		{"ガターより小さいコマはエラーなのだ", func(l *PageLayout) { l.Gutter = 500 }},
+		{"負の裁ち落としはエラーなのだ", func(l *PageLayout) { l.Bleed = -1 }},
+		{"重なり合うコマはエラーなのだ", func(l *PageLayout) { l.Panels[0].Y = 600 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := testLayout(ReadingOrderRTL)
+			tt.modify(&l)
+			if err := l.Validate(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
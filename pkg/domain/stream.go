@@ -0,0 +1,23 @@
+package domain
+
+// StreamEventKind はストリーミング生成で届くイベントの種類です。
+type StreamEventKind int
+
+const (
+	// StreamEventThought はモデルの思考過程のテキストです。
+	StreamEventThought StreamEventKind = iota
+	// StreamEventText は画像と併せて返される解説などのテキストです。
+	StreamEventText
+	// StreamEventImage は生成途中で届いた画像です。思考中の途中画像 (ドラフト) も含みますが、最終結果には含まれません。
+	StreamEventImage
+	// StreamEventFinal は検証済みの最終結果です。ストリームの最後に1回だけ届き、思考中の途中画像は含みません。
+	StreamEventFinal
+)
+
+// StreamEvent はストリーミング生成の途中経過または最終結果です。
+type StreamEvent struct {
+	Kind     StreamEventKind
+	Text     string          // Thought / Text の内容
+	Image    *GeneratedImage // Image の内容
+	Response *ImageResponse  // Final の内容
+}
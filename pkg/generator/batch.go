@@ -0,0 +1,169 @@
+package generator
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+)
+
+// DefaultBatchConcurrency は BatchOptions.Concurrency 未指定時の同時実行数です。
+const DefaultBatchConcurrency = 4
+
+// BatchOptions は GenerateBatch の実行方法を定義します。
+type BatchOptions struct {
+	Concurrency int                 // 同時に実行するリクエスト数 (0 以下は DefaultBatchConcurrency)
+	OnProgress  func(BatchProgress) // 各アイテムの完了時に呼ばれるコールバック (nil 可)
+}
+
+// BatchProgress は GenerateBatch の進捗を表します。
+type BatchProgress struct {
+	Index     int   // 完了したアイテムの入力順のインデックス
+	Completed int   // 完了したアイテム数
+	Total     int   // 全アイテム数
+	Err       error // 完了したアイテムのエラー
+}
+
+// BatchResult は GenerateBatch の各アイテムの結果です。
+type BatchResult struct {
+	Index    int
+	Response *domain.ImageResponse
+	Err      error
+}
+
+// GenerateBatch は複数のパネル生成を並列に実行し、入力と同じ順序で結果を返します。
+// 個々のアイテムの失敗は BatchResult.Err に格納され、他のアイテムの実行は継続されます。
+// 複数のアイテムで共有される参照画像は事前に一度だけ File API にアップロードされ、
+// ImageCacher を通じて以降のバッチでも再利用されます。
+// ctx がキャンセルされた場合、未実行のアイテムには ctx.Err() が格納され、戻り値のエラーにも ctx.Err() が返ります。
+func (g *GeminiGenerator) GenerateBatch(ctx context.Context, reqs []domain.ImageGenerationRequest, opts BatchOptions) ([]BatchResult, error) {
+	results := make([]BatchResult, len(reqs))
+	for i := range results {
+		results[i].Index = i
+	}
+	if len(reqs) == 0 {
+		return results, nil
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	reqs = g.uploadSharedReferences(ctx, reqs, concurrency)
+
+	var (
+		mu        sync.Mutex
+		completed int
+	)
+	runPool(concurrency, len(reqs), func(i int) {
+		var resp *domain.ImageResponse
+		err := ctx.Err()
+		if err == nil {
+			resp, err = g.GenerateMangaPanel(ctx, reqs[i])
+		}
+		results[i].Response = resp
+		results[i].Err = err
+
+		if opts.OnProgress == nil {
+			return
+		}
+		// コールバックは直列に呼び出し、呼び出し側での排他制御を不要にする
+		mu.Lock()
+		defer mu.Unlock()
+		completed++
+		opts.OnProgress(BatchProgress{Index: i, Completed: completed, Total: len(reqs), Err: err})
+	})
+
+	return results, ctx.Err()
+}
+
+// uploadSharedReferences は複数のアイテムで参照される URL を一度だけアップロードし、
+// 取得した File API の URI を設定したリクエストのコピーを返します。
+// コアが AssetManager を実装していない場合や、アップロードに失敗した URL は各アイテムで個別に取得されます。
+func (g *GeminiGenerator) uploadSharedReferences(ctx context.Context, reqs []domain.ImageGenerationRequest, concurrency int) []domain.ImageGenerationRequest {
+	assets, ok := g.core.(AssetManager)
+	if !ok {
+		return reqs
+	}
+
+	counts := make(map[string]int)
+	for _, req := range reqs {
+		if isUploadCandidate(req.Image) {
+			counts[req.Image.ReferenceURL]++
+		}
+	}
+	var shared []string
+	for url, n := range counts {
+		if n > 1 {
+			shared = append(shared, url)
+		}
+	}
+	if len(shared) == 0 {
+		return reqs
+	}
+
+	uploaded := make([]string, len(shared))
+	runPool(concurrency, len(shared), func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		uri, err := assets.UploadFile(ctx, shared[i])
+		if err != nil {
+			slog.WarnContext(ctx, "共有参照画像の事前アップロードに失敗しました。各アイテムで個別に取得します", "url", shared[i], "error", err)
+			return
+		}
+		uploaded[i] = uri
+	})
+
+	fileURIs := make(map[string]string, len(shared))
+	for i, url := range shared {
+		if uploaded[i] != "" {
+			fileURIs[url] = uploaded[i]
+		}
+	}
+
+	out := make([]domain.ImageGenerationRequest, len(reqs))
+	copy(out, reqs)
+	for i := range out {
+		if !isUploadCandidate(out[i].Image) {
+			continue
+		}
+		if uri, ok := fileURIs[out[i].Image.ReferenceURL]; ok {
+			out[i].Image.FileAPIURI = uri
+		}
+	}
+	return out
+}
+
+// isUploadCandidate は URL 参照のみを持つ (事前アップロードで置き換え可能な) 画像かどうかを判定します。
+func isUploadCandidate(uri domain.ImageURI) bool {
+	return uri.ReferenceURL != "" && uri.FileAPIURI == "" && len(uri.Data) == 0
+}
+
+// runPool は 0 から n-1 のインデックスに対して fn を最大 concurrency 並列で実行し、全ての完了を待ちます。
+// キャンセル時も全てのインデックスに対して fn が呼ばれるため、fn 側で ctx を確認してください。
+func runPool(concurrency, n int, fn func(i int)) {
+	if concurrency > n {
+		concurrency = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,448 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"google.golang.org/genai"
+)
+
+const (
+	// DefaultBatchAPIBaseURL は Gemini Developer API のエンドポイントです。
+	DefaultBatchAPIBaseURL = "https://generativelanguage.googleapis.com"
+	// DefaultBatchPollInterval はジョブ状態をポーリングする間隔のデフォルト値です。
+	DefaultBatchPollInterval = 30 * time.Second
+
+	batchAPIVersion    = "v1beta"
+	batchInputMimeType = "application/jsonl"
+)
+
+// BatchJobState はバッチジョブの状態です。値は REST API の BatchState と一致します。
+type BatchJobState string
+
+const (
+	BatchJobStatePending   BatchJobState = "BATCH_STATE_PENDING"
+	BatchJobStateRunning   BatchJobState = "BATCH_STATE_RUNNING"
+	BatchJobStateSucceeded BatchJobState = "BATCH_STATE_SUCCEEDED"
+	BatchJobStateFailed    BatchJobState = "BATCH_STATE_FAILED"
+	BatchJobStateCancelled BatchJobState = "BATCH_STATE_CANCELLED"
+	BatchJobStateExpired   BatchJobState = "BATCH_STATE_EXPIRED"
+)
+
+// IsTerminal はジョブがこれ以上状態遷移しないかどうかを返します。
+func (s BatchJobState) IsTerminal() bool {
+	switch s {
+	case BatchJobStateSucceeded, BatchJobStateFailed, BatchJobStateCancelled, BatchJobStateExpired:
+		return true
+	default:
+		return false
+	}
+}
+
+// BatchJobRequest はバッチジョブに含める1件のリクエストです。ID は結果の対応付けに使われ、ジョブ内で一意である必要があります。
+type BatchJobRequest struct {
+	ID      string
+	Request domain.ImagePageRequest
+}
+
+// BatchJobResult はバッチジョブの1件分の結果です。
+type BatchJobResult struct {
+	Response *domain.ImageResponse
+	Err      error
+}
+
+// BatchJob は投入済みのバッチジョブを表します。
+// JSON で保存しておけば、別プロセスから Wait / Results で結果を回収できます。
+type BatchJob struct {
+	Name          string           `json:"name"`
+	Model         string           `json:"model"`
+	State         BatchJobState    `json:"state"`
+	InputFile     string           `json:"input_file,omitempty"` // アップロードした入力 JSONL の File API 名 (Cleanup で削除)
+	ResponsesFile string           `json:"responses_file,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	Seeds         map[string]int64 `json:"seeds,omitempty"` // リクエスト ID ごとの指定シード
+}
+
+// BatchJobConfig は BatchJobSubmitter の接続設定です。
+type BatchJobConfig struct {
+	APIKey       string        // Gemini API キー (必須)
+	BaseURL      string        // 空の場合は DefaultBatchAPIBaseURL
+	HTTPClient   *http.Client  // nil の場合は http.DefaultClient
+	PollInterval time.Duration // 0 以下の場合は DefaultBatchPollInterval
+}
+
+// BatchJobSubmitter は Gemini Batch API を用いて大量の画像生成をオフラインで実行します。
+// リクエストは JSONL にシリアライズして File API にアップロードされ、結果はリクエスト ID ごとに返されます。
+type BatchJobSubmitter struct {
+	core         *GeminiImageCore
+	gen          *GeminiGenerator
+	model        string
+	apiKey       string
+	baseURL      string
+	httpClient   *http.Client
+	pollInterval time.Duration
+}
+
+// NewBatchJobSubmitter は core を用いて参照画像の準備・入力ファイルのアップロード・結果の解析を行う BatchJobSubmitter を作成します。
+// opts には同期生成の GeminiGenerator と同じオプション (WithStyleLibrary・WithCharacterRegistry・WithSafetySettings 等) を指定してください。
+// リクエストはそのオプションを適用したジェネレーターで組み立てられるため、同じリクエストは同期生成と同じ内容で送信されます。
+func NewBatchJobSubmitter(core *GeminiImageCore, model string, cfg BatchJobConfig, opts ...GeneratorOption) (*BatchJobSubmitter, error) {
+	if core == nil {
+		return nil, fmt.Errorf("%w: core is required", ErrMissingDependency)
+	}
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("%w: api key is required", ErrMissingDependency)
+	}
+	gen, err := NewGeminiGenerator(model, model, core, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &BatchJobSubmitter{
+		core:         core,
+		gen:          gen,
+		model:        model,
+		apiKey:       cfg.APIKey,
+		baseURL:      strings.TrimRight(cfg.BaseURL, "/"),
+		httpClient:   cfg.HTTPClient,
+		pollInterval: cfg.PollInterval,
+	}
+	if s.baseURL == "" {
+		s.baseURL = DefaultBatchAPIBaseURL
+	}
+	if s.httpClient == nil {
+		s.httpClient = http.DefaultClient
+	}
+	if s.pollInterval <= 0 {
+		s.pollInterval = DefaultBatchPollInterval
+	}
+	return s, nil
+}
+
+// batchInputLine は入力 JSONL の1行です。
+type batchInputLine struct {
+	Key     string               `json:"key"`
+	Request batchGenerateRequest `json:"request"`
+}
+
+// batchGenerateRequest は REST API の GenerateContentRequest に対応します。
+type batchGenerateRequest struct {
+	Contents          []*genai.Content       `json:"contents"`
+	SystemInstruction *genai.Content         `json:"systemInstruction,omitempty"`
+	GenerationConfig  *batchGenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []*genai.SafetySetting `json:"safetySettings,omitempty"`
+}
+
+type batchGenerationConfig struct {
+	CandidateCount   int32              `json:"candidateCount,omitempty"`
+	Seed             *int32             `json:"seed,omitempty"`
+	Temperature      *float32           `json:"temperature,omitempty"`
+	TopP             *float32           `json:"topP,omitempty"`
+	TopK             *float32           `json:"topK,omitempty"`
+	ResponseMIMEType string             `json:"responseMimeType,omitempty"`
+	ImageConfig      *genai.ImageConfig `json:"imageConfig,omitempty"`
+}
+
+// batchOutputLine は結果 JSONL の1行です。
+type batchOutputLine struct {
+	Key      string                         `json:"key"`
+	Response *genai.GenerateContentResponse `json:"response,omitempty"`
+	Error    *batchStatus                   `json:"error,omitempty"`
+}
+
+// batchStatus は google.rpc.Status に対応します。
+type batchStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// batchOperation は作成・取得 API が返す Operation です。
+type batchOperation struct {
+	Name     string `json:"name"`
+	Done     bool   `json:"done"`
+	Metadata struct {
+		Model  string        `json:"model"`
+		State  BatchJobState `json:"state"`
+		Output struct {
+			ResponsesFile string `json:"responsesFile"`
+		} `json:"output"`
+	} `json:"metadata"`
+	Error *batchStatus `json:"error,omitempty"`
+}
+
+// Submit はリクエストを JSONL に変換してアップロードし、バッチジョブを作成します。
+func (s *BatchJobSubmitter) Submit(ctx context.Context, displayName string, reqs []BatchJobRequest) (*BatchJob, error) {
+	if len(reqs) == 0 {
+		return nil, fmt.Errorf("batch job requires at least one request")
+	}
+
+	input, seeds, err := s.encodeInput(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+
+	_, fileName, err := s.core.aiClient.UploadFile(ctx, input, batchInputMimeType, displayName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload batch input: %w", err)
+	}
+
+	body := map[string]any{
+		"batch": map[string]any{
+			"displayName": displayName,
+			"inputConfig": map[string]string{"fileName": fileName},
+		},
+	}
+	var op batchOperation
+	path := fmt.Sprintf("/%s/models/%s:batchGenerateContent", batchAPIVersion, strings.TrimPrefix(s.model, "models/"))
+	if err := s.doJSON(ctx, http.MethodPost, path, body, &op); err != nil {
+		if delErr := s.core.aiClient.DeleteFile(ctx, fileName); delErr != nil {
+			slog.WarnContext(ctx, "バッチ入力ファイルの削除に失敗しました", "file", fileName, "error", delErr)
+		}
+		return nil, fmt.Errorf("failed to create batch job: %w", err)
+	}
+
+	job := &BatchJob{Name: op.Name, Model: s.model, State: BatchJobStatePending, InputFile: fileName, Seeds: seeds}
+	job.apply(&op)
+	return job, nil
+}
+
+// encodeInput は各リクエストを参照画像込みで JSONL の行に変換します。
+func (s *BatchJobSubmitter) encodeInput(ctx context.Context, reqs []BatchJobRequest) ([]byte, map[string]int64, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	seeds := make(map[string]int64)
+	seen := make(map[string]bool, len(reqs))
+
+	for _, r := range reqs {
+		if r.ID == "" {
+			return nil, nil, fmt.Errorf("batch request id cannot be empty")
+		}
+		if seen[r.ID] {
+			return nil, nil, fmt.Errorf("duplicate batch request id: %s", r.ID)
+		}
+		seen[r.ID] = true
+		// バッチジョブはモデル単位で作成されるため、リクエストごとのモデル指定には対応できない
+		if r.Request.Model != "" && r.Request.Model != s.model {
+			return nil, nil, fmt.Errorf("batch request %s targets model %s, but the job runs on %s", r.ID, r.Request.Model, s.model)
+		}
+
+		parts, _, opts, err := s.gen.prepareRequest(ctx, r.Request)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to prepare batch request %s: %w", r.ID, err)
+		}
+		if r.Request.Seed != nil {
+			seeds[r.ID] = *r.Request.Seed
+		}
+
+		line := batchInputLine{Key: r.ID, Request: toBatchRequest(parts, opts)}
+		if err := enc.Encode(line); err != nil {
+			return nil, nil, fmt.Errorf("failed to encode batch request %s: %w", r.ID, err)
+		}
+	}
+	return buf.Bytes(), seeds, nil
+}
+
+// toBatchRequest は GenerateWithParts と同じ設定を REST のリクエスト形式に変換します。
+func toBatchRequest(parts []*genai.Part, opts GenerateOptions) batchGenerateRequest {
+	req := batchGenerateRequest{
+		Contents:       []*genai.Content{{Role: genai.RoleUser, Parts: parts}},
+		SafetySettings: opts.SafetySettings,
+	}
+	if opts.SystemPrompt != "" {
+		req.SystemInstruction = &genai.Content{Parts: []*genai.Part{{Text: opts.SystemPrompt}}}
+	}
+
+	cfg := &batchGenerationConfig{
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		TopK:             opts.TopK,
+		ResponseMIMEType: opts.ResponseMIMEType,
+		Seed:             seedToInt32(opts.Seed),
+	}
+	if opts.CandidateCount != nil {
+		cfg.CandidateCount = *opts.CandidateCount
+	}
+	if opts.AspectRatio != "" || opts.ImageSize != "" {
+		cfg.ImageConfig = &genai.ImageConfig{AspectRatio: opts.AspectRatio, ImageSize: opts.ImageSize}
+	}
+	req.GenerationConfig = cfg
+	return req
+}
+
+// Refresh はジョブの最新の状態を取得して job を更新します。
+func (s *BatchJobSubmitter) Refresh(ctx context.Context, job *BatchJob) error {
+	var op batchOperation
+	if err := s.doJSON(ctx, http.MethodGet, "/"+batchAPIVersion+"/"+job.Name, nil, &op); err != nil {
+		return fmt.Errorf("failed to get batch job %s: %w", job.Name, err)
+	}
+	job.apply(&op)
+	return nil
+}
+
+// Wait はジョブが終了状態になるまでポーリングします。
+// ジョブが成功しなかった場合は ErrBatchJobFailed を返します。
+func (s *BatchJobSubmitter) Wait(ctx context.Context, job *BatchJob) error {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Refresh(ctx, job); err != nil {
+			return err
+		}
+		if job.State.IsTerminal() {
+			if job.State != BatchJobStateSucceeded {
+				return fmt.Errorf("%w: %s is %s: %s", ErrBatchJobFailed, job.Name, job.State, job.Error)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Results は成功したジョブの結果ファイルを取得し、リクエスト ID ごとのレスポンスに変換します。
+// 個々のリクエストの失敗は BatchJobResult.Err に格納されます。
+func (s *BatchJobSubmitter) Results(ctx context.Context, job *BatchJob) (map[string]BatchJobResult, error) {
+	if job.State != BatchJobStateSucceeded || job.ResponsesFile == "" {
+		return nil, fmt.Errorf("%w: %s has no results (state: %s)", ErrBatchJobFailed, job.Name, job.State)
+	}
+
+	path := fmt.Sprintf("/download/%s/%s:download?alt=media", batchAPIVersion, job.ResponsesFile)
+	body, err := s.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download batch results: %w", err)
+	}
+	defer body.Close()
+
+	// インライン画像を含む行は非常に長くなるため、行単位ではなく JSON の値単位で読み込む
+	results := make(map[string]BatchJobResult)
+	dec := json.NewDecoder(body)
+	for {
+		var out batchOutputLine
+		if err := dec.Decode(&out); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode batch result line: %w", err)
+		}
+		results[out.Key] = s.toResult(job, &out)
+	}
+	return results, nil
+}
+
+// Cleanup は Submit でアップロードした入力ファイルを File API から削除します。
+// 入力ファイルはジョブの実行中に参照されるため、ジョブが終了状態になった後に呼び出してください。
+func (s *BatchJobSubmitter) Cleanup(ctx context.Context, job *BatchJob) error {
+	if !job.State.IsTerminal() {
+		return fmt.Errorf("batch job %s is still %s", job.Name, job.State)
+	}
+	if job.InputFile == "" {
+		return nil
+	}
+	if err := s.core.aiClient.DeleteFile(ctx, job.InputFile); err != nil {
+		return fmt.Errorf("failed to delete batch input %s: %w", job.InputFile, err)
+	}
+	job.InputFile = ""
+	return nil
+}
+
+// toResult は結果の1行をドメインのレスポンスに変換します。
+func (s *BatchJobSubmitter) toResult(job *BatchJob, line *batchOutputLine) BatchJobResult {
+	if line.Error != nil {
+		return BatchJobResult{Err: classifyClientError(line.Error.toAPIError())}
+	}
+	out, err := s.core.ParseToResponse(&gemini.Response{RawResponse: line.Response}, job.Seeds[line.Key])
+	if err != nil {
+		return BatchJobResult{Err: err}
+	}
+	return BatchJobResult{Response: newImageResponse(out, job.Model)}
+}
+
+// apply は Operation の内容を job に反映します。
+func (job *BatchJob) apply(op *batchOperation) {
+	if op.Name != "" {
+		job.Name = op.Name
+	}
+	if op.Metadata.State != "" {
+		job.State = op.Metadata.State
+	}
+	if op.Metadata.Output.ResponsesFile != "" {
+		job.ResponsesFile = op.Metadata.Output.ResponsesFile
+	}
+	if op.Error != nil {
+		job.Error = op.Error.Message
+		if !job.State.IsTerminal() {
+			job.State = BatchJobStateFailed
+		}
+	}
+}
+
+func (st *batchStatus) toAPIError() genai.APIError {
+	return genai.APIError{Code: st.Code, Message: st.Message, Status: st.Status}
+}
+
+// doJSON は JSON のリクエストを送信し、レスポンスを out にデコードします。
+func (s *BatchJobSubmitter) doJSON(ctx context.Context, method, path string, in, out any) error {
+	var reqBody io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	body, err := s.do(ctx, method, path, reqBody)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	return json.NewDecoder(body).Decode(out)
+}
+
+// do は API キーを付与してリクエストを送信します。
+// 2xx 以外のレスポンスは genai.APIError として返し、classifyClientError で分類できるようにします。
+func (s *BatchJobSubmitter) do(ctx context.Context, method, path string, reqBody io.Reader) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", s.apiKey)
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.Body, nil
+	}
+	defer resp.Body.Close()
+
+	var errBody struct {
+		Error batchStatus `json:"error"`
+	}
+	data, _ := io.ReadAll(resp.Body)
+	if err := json.Unmarshal(data, &errBody); err != nil || errBody.Error.Message == "" {
+		errBody.Error = batchStatus{Code: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+	if errBody.Error.Code == 0 {
+		errBody.Error.Code = resp.StatusCode
+	}
+	return nil, classifyClientError(errBody.Error.toAPIError())
+}
@@ -0,0 +1,202 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBatchAPI は Batch API のエンドポイントを模したテスト用サーバーです。
+type fakeBatchAPI struct {
+	mu         sync.Mutex
+	polls      int
+	runUntil   int // この回数の取得までは RUNNING を返す
+	finalState BatchJobState
+	created    map[string]any
+	results    string
+	createErr  bool // ジョブ作成を 400 で失敗させる
+}
+
+func (f *fakeBatchAPI) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1beta/models/{model}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-key", r.Header.Get("x-goog-api-key"))
+		assert.Equal(t, "image-model:batchGenerateContent", r.PathValue("model"))
+		if f.createErr {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"code":400,"message":"invalid input","status":"INVALID_ARGUMENT"}}`)
+			return
+		}
+		f.mu.Lock()
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&f.created))
+		f.mu.Unlock()
+		fmt.Fprint(w, `{"name":"batches/job-1","metadata":{"state":"BATCH_STATE_PENDING"}}`)
+	})
+	mux.HandleFunc("GET /v1beta/batches/job-1", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.polls++
+		if f.polls <= f.runUntil {
+			fmt.Fprint(w, `{"name":"batches/job-1","metadata":{"state":"BATCH_STATE_RUNNING"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"name":"batches/job-1","done":true,"metadata":{"state":%q,"output":{"responsesFile":"files/out-1"}}}`, f.finalState)
+	})
+	mux.HandleFunc("GET /download/v1beta/files/out-1:download", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "media", r.URL.Query().Get("alt"))
+		fmt.Fprint(w, f.results)
+	})
+	return mux
+}
+
+func TestBatchJobSubmitter(t *testing.T) {
+	ctx := context.Background()
+
+	newSubmitter := func(t *testing.T, api *fakeBatchAPI, opts ...GeneratorOption) (*BatchJobSubmitter, *mockAIClient) {
+		srv := httptest.NewServer(api.handler(t))
+		t.Cleanup(srv.Close)
+
+		ai := &mockAIClient{}
+		core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{data: mockImagePNG}, nil, time.Hour)
+		require.NoError(t, err)
+		s, err := NewBatchJobSubmitter(core, "image-model", BatchJobConfig{
+			APIKey:       "test-key",
+			BaseURL:      srv.URL,
+			HTTPClient:   srv.Client(),
+			PollInterval: time.Millisecond,
+		}, opts...)
+		require.NoError(t, err)
+		return s, ai
+	}
+
+	seed := int64(42)
+	reqs := []BatchJobRequest{
+		{ID: "panel-1", Request: domain.ImagePageRequest{Prompt: "a cat", AspectRatio: "16:9", Seed: &seed, SystemPrompt: "manga style"}},
+		{ID: "panel-2", Request: domain.ImagePageRequest{Prompt: "a dog"}},
+	}
+
+	t.Run("リクエストを JSONL に変換してジョブを作成する", func(t *testing.T) {
+		api := &fakeBatchAPI{}
+		s, ai := newSubmitter(t, api)
+
+		job, err := s.Submit(ctx, "nightly", reqs)
+		require.NoError(t, err)
+		assert.Equal(t, "batches/job-1", job.Name)
+		assert.Equal(t, BatchJobStatePending, job.State)
+
+		batch := api.created["batch"].(map[string]any)
+		assert.Equal(t, MockFileUploadName, batch["inputConfig"].(map[string]any)["fileName"])
+
+		var lines []map[string]any
+		scanner := bufio.NewScanner(bytes.NewReader(ai.lastUploadData))
+		for scanner.Scan() {
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+			lines = append(lines, line)
+		}
+		require.Len(t, lines, 2)
+		assert.Equal(t, "panel-1", lines[0]["key"])
+		request := lines[0]["request"].(map[string]any)
+		cfg := request["generationConfig"].(map[string]any)
+		assert.Equal(t, float64(42), cfg["seed"])
+		assert.Equal(t, "16:9", cfg["imageConfig"].(map[string]any)["aspectRatio"])
+		assert.Contains(t, request["systemInstruction"], "parts")
+	})
+
+	t.Run("ジョブの作成に失敗した場合は入力ファイルを削除する", func(t *testing.T) {
+		s, ai := newSubmitter(t, &fakeBatchAPI{createErr: true})
+		_, err := s.Submit(ctx, "nightly", reqs)
+		assert.Error(t, err)
+		assert.True(t, ai.deleteCalled)
+		assert.Equal(t, MockFileUploadName, ai.lastFileName)
+	})
+
+	t.Run("ジェネレーターのオプションをリクエストの組み立てに適用する", func(t *testing.T) {
+		styles, err := NewStyleLibrary(StylePreset{Name: "shonen", SystemPrompt: "Screentone shading."})
+		require.NoError(t, err)
+		s, ai := newSubmitter(t, &fakeBatchAPI{},
+			WithStyleLibrary(styles),
+			WithSafetySettings(domain.SafetySetting{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_ONLY_HIGH"}),
+		)
+
+		_, err = s.Submit(ctx, "nightly", []BatchJobRequest{{ID: "styled", Request: domain.ImagePageRequest{Prompt: "a cat", Style: "shonen"}}})
+		require.NoError(t, err)
+
+		var line map[string]any
+		require.NoError(t, json.Unmarshal(bytes.TrimSpace(ai.lastUploadData), &line))
+		request := line["request"].(map[string]any)
+		assert.Contains(t, fmt.Sprint(request["systemInstruction"]), "Screentone shading.")
+		require.Len(t, request["safetySettings"], 1)
+		assert.Equal(t, "BLOCK_ONLY_HIGH", request["safetySettings"].([]any)[0].(map[string]any)["threshold"])
+	})
+
+	t.Run("ID の重複はエラー", func(t *testing.T) {
+		s, _ := newSubmitter(t, &fakeBatchAPI{})
+		_, err := s.Submit(ctx, "nightly", []BatchJobRequest{{ID: "a", Request: reqs[1].Request}, {ID: "a", Request: reqs[1].Request}})
+		assert.Error(t, err)
+	})
+
+	t.Run("完了までポーリングし結果をリクエスト ID に対応付ける", func(t *testing.T) {
+		api := &fakeBatchAPI{
+			runUntil:   2,
+			finalState: BatchJobStateSucceeded,
+			results: `{"key":"panel-1","response":{"candidates":[{"finishReason":"STOP","content":{"parts":[{"inlineData":{"mimeType":"image/png","data":"aW1n"}}]}}]}}
+{"key":"panel-2","response":{"candidates":[{"finishReason":"IMAGE_SAFETY"}]}}
+{"key":"panel-3","error":{"code":429,"message":"quota","status":"RESOURCE_EXHAUSTED"}}
+`,
+		}
+		s, ai := newSubmitter(t, api)
+		job, err := s.Submit(ctx, "nightly", reqs)
+		require.NoError(t, err)
+		assert.Equal(t, MockFileUploadName, job.InputFile)
+		assert.Error(t, s.Cleanup(ctx, job), "the input file is still needed while the job runs")
+		assert.False(t, ai.deleteCalled)
+
+		require.NoError(t, s.Wait(ctx, job))
+		assert.Equal(t, 3, api.polls)
+		assert.Equal(t, "files/out-1", job.ResponsesFile)
+
+		results, err := s.Results(ctx, job)
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		require.NoError(t, s.Cleanup(ctx, job))
+		assert.True(t, ai.deleteCalled)
+		assert.Equal(t, MockFileUploadName, ai.lastFileName)
+		assert.Empty(t, job.InputFile)
+
+		require.NoError(t, results["panel-1"].Err)
+		assert.Equal(t, []byte("img"), results["panel-1"].Response.Data)
+		assert.Equal(t, int64(42), results["panel-1"].Response.UsedSeed)
+		assert.Equal(t, "image-model", results["panel-1"].Response.Model)
+		assert.ErrorIs(t, results["panel-2"].Err, ErrSafetyBlocked)
+		assert.ErrorIs(t, results["panel-3"].Err, ErrQuotaExceeded)
+	})
+
+	t.Run("失敗したジョブは ErrBatchJobFailed を返す", func(t *testing.T) {
+		s, _ := newSubmitter(t, &fakeBatchAPI{finalState: BatchJobStateFailed})
+		job := &BatchJob{Name: "batches/job-1"}
+
+		assert.ErrorIs(t, s.Wait(ctx, job), ErrBatchJobFailed)
+		_, err := s.Results(ctx, job)
+		assert.ErrorIs(t, err, ErrBatchJobFailed)
+	})
+
+	t.Run("API キーがない場合はエラー", func(t *testing.T) {
+		core, err := NewGeminiImageCore(&mockAIClient{}, &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+		require.NoError(t, err)
+		_, err = NewBatchJobSubmitter(core, "image-model", BatchJobConfig{})
+		assert.ErrorIs(t, err, ErrMissingDependency)
+	})
+}
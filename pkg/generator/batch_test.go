@@ -0,0 +1,159 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// batchExecutor は並列実行に対応した ImageExecutor / AssetManager のモックです。
+type batchExecutor struct {
+	mu       sync.Mutex
+	uploads  map[string]int
+	prompts  map[string][]*genai.Part
+	running  atomic.Int32
+	peak     atomic.Int32
+	failWith map[string]error
+	delay    time.Duration
+}
+
+func newBatchExecutor() *batchExecutor {
+	return &batchExecutor{
+		uploads:  make(map[string]int),
+		prompts:  make(map[string][]*genai.Part),
+		failWith: make(map[string]error),
+	}
+}
+
+func (b *batchExecutor) ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error) {
+	n := b.running.Add(1)
+	defer b.running.Add(-1)
+	for {
+		peak := b.peak.Load()
+		if n <= peak || b.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(b.delay)
+
+	prompt := parts[len(parts)-1].Text
+	b.mu.Lock()
+	b.prompts[prompt] = parts
+	b.mu.Unlock()
+	if err, ok := b.failWith[prompt]; ok {
+		return nil, err
+	}
+	return &domain.ImageResponse{Data: []byte(prompt), MimeType: "image/png", Model: model}, nil
+}
+
+func (b *batchExecutor) PrepareImagePart(ctx context.Context, rawURL string) (*genai.Part, error) {
+	return &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte(rawURL)}}, nil
+}
+
+func (b *batchExecutor) PrepareInlinePart(ctx context.Context, data []byte, mimeType string) (*genai.Part, error) {
+	return &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: data}}, nil
+}
+
+func (b *batchExecutor) UploadFile(ctx context.Context, fileURI string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.uploads[fileURI]++
+	return "files/" + fileURI, nil
+}
+
+func (b *batchExecutor) DeleteFile(ctx context.Context, fileURI string) error { return nil }
+
+func TestGeminiGenerator_GenerateBatch(t *testing.T) {
+	ctx := context.Background()
+
+	newGenerator := func(exec *batchExecutor) *GeminiGenerator {
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		require.NoError(t, err)
+		return g
+	}
+
+	t.Run("入力と同じ順序で結果を返し、個別の失敗は他に影響しない", func(t *testing.T) {
+		exec := newBatchExecutor()
+		exec.failWith["p1"] = errors.New("boom")
+		g := newGenerator(exec)
+
+		reqs := []domain.ImageGenerationRequest{{Prompt: "p0"}, {Prompt: "p1"}, {Prompt: "p2"}}
+		results, err := g.GenerateBatch(ctx, reqs, BatchOptions{Concurrency: 2})
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		assert.Equal(t, []byte("p0"), results[0].Response.Data)
+		assert.Error(t, results[1].Err)
+		assert.Nil(t, results[1].Response)
+		assert.Equal(t, 2, results[2].Index)
+		assert.Equal(t, []byte("p2"), results[2].Response.Data)
+	})
+
+	t.Run("同時実行数を上限として並列実行する", func(t *testing.T) {
+		exec := newBatchExecutor()
+		exec.delay = 10 * time.Millisecond
+		g := newGenerator(exec)
+
+		reqs := make([]domain.ImageGenerationRequest, 8)
+		for i := range reqs {
+			reqs[i].Prompt = string(rune('a' + i))
+		}
+		_, err := g.GenerateBatch(ctx, reqs, BatchOptions{Concurrency: 3})
+		require.NoError(t, err)
+		assert.LessOrEqual(t, exec.peak.Load(), int32(3))
+		assert.Greater(t, exec.peak.Load(), int32(1))
+	})
+
+	t.Run("進捗コールバックが全アイテム分呼ばれる", func(t *testing.T) {
+		g := newGenerator(newBatchExecutor())
+		var progress []BatchProgress
+		reqs := []domain.ImageGenerationRequest{{Prompt: "a"}, {Prompt: "b"}, {Prompt: "c"}}
+
+		_, err := g.GenerateBatch(ctx, reqs, BatchOptions{OnProgress: func(p BatchProgress) {
+			progress = append(progress, p)
+		}})
+		require.NoError(t, err)
+		require.Len(t, progress, 3)
+		assert.Equal(t, 3, progress[2].Completed)
+		assert.Equal(t, 3, progress[2].Total)
+	})
+
+	t.Run("共有される参照画像は一度だけアップロードされる", func(t *testing.T) {
+		exec := newBatchExecutor()
+		g := newGenerator(exec)
+		shared := domain.ImageURI{ReferenceURL: "gs://bucket/hero.png"}
+		reqs := []domain.ImageGenerationRequest{
+			{Prompt: "a", Image: shared},
+			{Prompt: "b", Image: shared},
+			{Prompt: "c", Image: domain.ImageURI{ReferenceURL: "gs://bucket/only-once.png"}},
+		}
+
+		_, err := g.GenerateBatch(ctx, reqs, BatchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int{"gs://bucket/hero.png": 1}, exec.uploads)
+		assert.Equal(t, "files/gs://bucket/hero.png", exec.prompts["a"][0].FileData.FileURI)
+		assert.Equal(t, "files/gs://bucket/hero.png", exec.prompts["b"][0].FileData.FileURI)
+		assert.NotNil(t, exec.prompts["c"][0].InlineData)
+		assert.Empty(t, reqs[0].Image.FileAPIURI, "caller's requests must not be modified")
+	})
+
+	t.Run("キャンセル時は未実行のアイテムにコンテキストのエラーを設定する", func(t *testing.T) {
+		g := newGenerator(newBatchExecutor())
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		results, err := g.GenerateBatch(cctx, []domain.ImageGenerationRequest{{Prompt: "a"}, {Prompt: "b"}}, BatchOptions{})
+		assert.ErrorIs(t, err, context.Canceled)
+		for _, r := range results {
+			assert.ErrorIs(t, r.Err, context.Canceled)
+		}
+	})
+}
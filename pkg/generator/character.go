@@ -0,0 +1,206 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+)
+
+// Character は登場キャラクターの参照シートと外見上の特徴です。
+// リクエストからは CharacterIDs で ID を指定して参照します。
+type Character struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Traits      []string          `json:"traits,omitempty"`      // 髪型・服装など、全コマで一貫させたい特徴
+	Front       string            `json:"front,omitempty"`       // 正面の参照画像 URL
+	Side        string            `json:"side,omitempty"`        // 側面の参照画像 URL
+	Expressions map[string]string `json:"expressions,omitempty"` // 表情名から参照画像 URL への対応
+}
+
+// sheets は指定された表情で使用する参照画像の URL を返します。
+// 表情を指定した場合は正面とその表情、指定しない場合は正面と側面を返します。
+func (c Character) sheets(expression string) ([]string, error) {
+	var urls []string
+	if c.Front != "" {
+		urls = append(urls, c.Front)
+	}
+	if expression == "" {
+		if c.Side != "" {
+			urls = append(urls, c.Side)
+		}
+		return urls, nil
+	}
+
+	url, ok := c.Expressions[expression]
+	if !ok {
+		return nil, fmt.Errorf("character %q has no expression %q", c.ID, expression)
+	}
+	return append(urls, url), nil
+}
+
+// CharacterRegistry はキャラクター ID と参照シートを対応付けます。
+// 参照シートは AssetManager 経由で File API にアップロードされ、キャッシュにより再利用されます。
+// 複数の goroutine から同時に使用できます。
+type CharacterRegistry struct {
+	assets     AssetManager
+	mu         sync.RWMutex
+	characters map[string]Character
+}
+
+// NewCharacterRegistry は新しい CharacterRegistry を作成します。
+func NewCharacterRegistry(assets AssetManager) (*CharacterRegistry, error) {
+	if assets == nil {
+		return nil, fmt.Errorf("%w: assets (AssetManager) is required", ErrMissingDependency)
+	}
+	return &CharacterRegistry{
+		assets:     assets,
+		characters: make(map[string]Character),
+	}, nil
+}
+
+// Register はキャラクターを登録します。同じ ID のキャラクターは上書きされます。
+func (r *CharacterRegistry) Register(c Character) error {
+	if c.ID == "" {
+		return fmt.Errorf("character ID is required")
+	}
+	if strings.Contains(c.ID, ":") {
+		return fmt.Errorf("character ID %q must not contain ':'", c.ID)
+	}
+	if c.Front == "" && c.Side == "" && len(c.Expressions) == 0 {
+		return fmt.Errorf("character %q has no reference sheets", c.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.characters[c.ID] = c
+	return nil
+}
+
+// Get は登録済みのキャラクターを返します。
+func (r *CharacterRegistry) Get(id string) (Character, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.characters[id]
+	return c, ok
+}
+
+// IDs は登録済みのキャラクター ID を昇順で返します。
+func (r *CharacterRegistry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	ids := make([]string, 0, len(r.characters))
+	for id := range r.characters {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Resolve はキャラクター参照 ("id" または "id:表情") を File API の参照画像と、
+// プロンプトに挿入する説明ブロックに変換します。未登録の ID はエラーになります。
+// 同じキャラクターを複数回指定した場合 ("hero" と "hero:angry" など) も、参照シートと説明行は1つにまとめます。
+// アップロードできなかったシートは FileAPIURI を空のまま返し、呼び出し側の ReferencePolicy に扱いを委ねます。
+func (r *CharacterRegistry) Resolve(ctx context.Context, refs []string) ([]domain.ImageURI, string, error) {
+	var images []domain.ImageURI
+	var order []Character
+	expressions := make(map[string][]string)
+	seenURLs := make(map[string]bool)
+
+	for _, ref := range refs {
+		id, expression, _ := strings.Cut(ref, ":")
+		c, ok := r.Get(id)
+		if !ok {
+			return nil, "", fmt.Errorf("unknown character %q", id)
+		}
+		urls, err := c.sheets(expression)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if _, ok := expressions[id]; !ok {
+			order = append(order, c)
+			expressions[id] = nil
+		}
+		if expression != "" && !slices.Contains(expressions[id], expression) {
+			expressions[id] = append(expressions[id], expression)
+		}
+
+		for _, url := range urls {
+			if seenURLs[url] {
+				continue
+			}
+			seenURLs[url] = true
+
+			sheet := domain.ImageURI{ReferenceURL: url}
+			if uri, err := r.assets.UploadFile(ctx, url); err == nil {
+				sheet.FileAPIURI = uri
+			} else {
+				slog.DebugContext(ctx, "参照シートのアップロードに失敗しました。参照画像として個別に取得します", "character", id, "url", url, "error", err)
+			}
+			images = append(images, sheet)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("[Characters]\nKeep each character's appearance consistent with their reference images:")
+	for _, c := range order {
+		sb.WriteString("\n")
+		sb.WriteString(describeCharacter(c, expressions[c.ID]))
+	}
+	return images, sb.String(), nil
+}
+
+// describeCharacter はキャラクター1人分の説明行を組み立てます。
+func describeCharacter(c Character, expressions []string) string {
+	name := c.Name
+	if name == "" {
+		name = c.ID
+	}
+	line := "- " + name
+	if len(c.Traits) > 0 {
+		line += ": " + strings.Join(c.Traits, ", ")
+	}
+	if len(expressions) > 0 {
+		line += fmt.Sprintf(" (expression: %s)", strings.Join(expressions, ", "))
+	}
+	return line
+}
+
+// WithCharacterRegistry はリクエストの CharacterIDs を解決するレジストリを設定します。
+func WithCharacterRegistry(r *CharacterRegistry) GeneratorOption {
+	return func(g *GeminiGenerator) {
+		g.characters = r
+	}
+}
+
+// characterContext はキャラクター参照を解決し、参照シートと説明ブロックを返します。
+// 参照がない場合は何も返しません。
+func (g *GeminiGenerator) characterContext(ctx context.Context, ids []string) ([]domain.ImageURI, string, error) {
+	if len(ids) == 0 {
+		return nil, "", nil
+	}
+	if g.characters == nil {
+		return nil, "", fmt.Errorf("%w: CharacterIDs requires a CharacterRegistry", ErrMissingDependency)
+	}
+	return g.characters.Resolve(ctx, ids)
+}
+
+// resolveCharacters は参照シートを参照画像の先頭に、説明ブロックをプロンプトの先頭に追加したリクエストを返します。
+func (g *GeminiGenerator) resolveCharacters(ctx context.Context, req domain.ImagePageRequest) (domain.ImagePageRequest, error) {
+	if len(req.CharacterIDs) == 0 {
+		return req, nil
+	}
+	sheets, block, err := g.characterContext(ctx, req.CharacterIDs)
+	if err != nil {
+		return req, err
+	}
+	req.Images = append(sheets, req.Images...)
+	req.Prompt = joinNonEmpty("\n\n", block, req.Prompt)
+	return req, nil
+}
@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAssets はアップロードされた URL を記録する AssetManager のモックです。
+type fakeAssets struct {
+	uploads []string
+	err     error
+}
+
+func (f *fakeAssets) UploadFile(ctx context.Context, fileURI string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	f.uploads = append(f.uploads, fileURI)
+	return "files/" + fileURI, nil
+}
+
+func (f *fakeAssets) DeleteFile(ctx context.Context, fileURI string) error { return nil }
+
+func newTestCharacterRegistry(t *testing.T, assets AssetManager) *CharacterRegistry {
+	t.Helper()
+	r, err := NewCharacterRegistry(assets)
+	require.NoError(t, err)
+	require.NoError(t, r.Register(Character{
+		ID:          "aoi",
+		Name:        "Aoi",
+		Traits:      []string{"short black hair", "red scarf"},
+		Front:       "gs://sheets/aoi-front.png",
+		Side:        "gs://sheets/aoi-side.png",
+		Expressions: map[string]string{"angry": "gs://sheets/aoi-angry.png"},
+	}))
+	return r
+}
+
+func TestCharacterRegistry_Register(t *testing.T) {
+	r, err := NewCharacterRegistry(&fakeAssets{})
+	require.NoError(t, err)
+
+	assert.Error(t, r.Register(Character{Front: "gs://sheets/a.png"}), "ID is required")
+	assert.Error(t, r.Register(Character{ID: "a:b", Front: "gs://sheets/a.png"}), "':' is reserved for expressions")
+	assert.Error(t, r.Register(Character{ID: "a"}), "at least one sheet is required")
+
+	require.NoError(t, r.Register(Character{ID: "b", Front: "gs://sheets/b.png"}))
+	require.NoError(t, r.Register(Character{ID: "a", Front: "gs://sheets/a.png"}))
+	assert.Equal(t, []string{"a", "b"}, r.IDs())
+
+	_, err = NewCharacterRegistry(nil)
+	assert.ErrorIs(t, err, ErrMissingDependency)
+}
+
+func TestCharacterRegistry_Resolve(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("表情を指定しない場合は正面と側面のシートを使う", func(t *testing.T) {
+		assets := &fakeAssets{}
+		images, block, err := newTestCharacterRegistry(t, assets).Resolve(ctx, []string{"aoi"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"gs://sheets/aoi-front.png", "gs://sheets/aoi-side.png"}, assets.uploads)
+		require.Len(t, images, 2)
+		assert.Equal(t, "files/gs://sheets/aoi-front.png", images[0].FileAPIURI)
+		assert.Contains(t, block, "- Aoi: short black hair, red scarf")
+	})
+
+	t.Run("表情を指定した場合は正面とその表情のシートを使う", func(t *testing.T) {
+		assets := &fakeAssets{}
+		_, block, err := newTestCharacterRegistry(t, assets).Resolve(ctx, []string{"aoi:angry"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"gs://sheets/aoi-front.png", "gs://sheets/aoi-angry.png"}, assets.uploads)
+		assert.Contains(t, block, "(expression: angry)")
+	})
+
+	t.Run("未登録の ID や表情はエラー", func(t *testing.T) {
+		r := newTestCharacterRegistry(t, &fakeAssets{})
+		_, _, err := r.Resolve(ctx, []string{"ren"})
+		assert.Error(t, err)
+		_, _, err = r.Resolve(ctx, []string{"aoi:sad"})
+		assert.Error(t, err)
+	})
+
+	t.Run("同じキャラクターの重複指定はシートと説明をまとめる", func(t *testing.T) {
+		assets := &fakeAssets{}
+		images, block, err := newTestCharacterRegistry(t, assets).Resolve(ctx, []string{"aoi", "aoi:angry", "aoi"})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"gs://sheets/aoi-front.png", "gs://sheets/aoi-side.png", "gs://sheets/aoi-angry.png"}, assets.uploads)
+		assert.Len(t, images, 3)
+		assert.Equal(t, 1, strings.Count(block, "- Aoi"))
+		assert.Contains(t, block, "(expression: angry)")
+	})
+
+	t.Run("アップロードに失敗したシートは File API URI なしで返す", func(t *testing.T) {
+		images, _, err := newTestCharacterRegistry(t, &fakeAssets{err: errors.New("upload failed")}).Resolve(ctx, []string{"aoi"})
+		require.NoError(t, err)
+		require.Len(t, images, 2)
+		assert.Equal(t, "gs://sheets/aoi-front.png", images[0].ReferenceURL)
+		assert.Empty(t, images[0].FileAPIURI)
+	})
+}
+
+func TestGeminiGenerator_Characters(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("参照シートとキャラクターの説明がリクエストに追加される", func(t *testing.T) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec,
+			WithCharacterRegistry(newTestCharacterRegistry(t, &fakeAssets{})))
+		require.NoError(t, err)
+
+		resp, err := g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{
+			Prompt:       "Aoi runs through the rain",
+			Image:        domain.ImageURI{ReferenceURL: "gs://bucket/background.png"},
+			CharacterIDs: []string{"aoi"},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, exec.lastParts, 4)
+		assert.Equal(t, "files/gs://sheets/aoi-front.png", exec.lastParts[0].FileData.FileURI)
+		assert.Equal(t, "files/gs://sheets/aoi-side.png", exec.lastParts[1].FileData.FileURI)
+		prompt := exec.lastParts[3].Text
+		assert.Contains(t, prompt, "[Characters]")
+		assert.Contains(t, prompt, "Aoi runs through the rain")
+		assert.Len(t, resp.UsedReferences, 3)
+	})
+
+	t.Run("シートを取得できない場合は ReferencePolicy に従う", func(t *testing.T) {
+		fetchErr := errors.New("not found")
+		exec := &mockExecutor{errs: map[string]error{"gs://sheets/aoi-side.png": fetchErr}}
+		g, err := NewGeminiGenerator("model", "quality-model", exec,
+			WithCharacterRegistry(newTestCharacterRegistry(t, &fakeAssets{err: errors.New("upload failed")})))
+		require.NoError(t, err)
+
+		resp, err := g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "panel", CharacterIDs: []string{"aoi"}})
+		require.NoError(t, err)
+		require.Len(t, resp.SkippedReferences, 1)
+		assert.Equal(t, "gs://sheets/aoi-side.png", resp.SkippedReferences[0].Image.ReferenceURL)
+		assert.Equal(t, []byte("gs://sheets/aoi-front.png"), exec.lastParts[0].InlineData.Data)
+
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{
+			Prompt:          "panel",
+			CharacterIDs:    []string{"aoi"},
+			ReferencePolicy: domain.FailOnMissingReference,
+		})
+		assert.ErrorIs(t, err, fetchErr)
+	})
+
+	t.Run("レジストリが未設定の場合はエラー", func(t *testing.T) {
+		g, err := NewGeminiGenerator("model", "quality-model", &mockExecutor{})
+		require.NoError(t, err)
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "panel", CharacterIDs: []string{"aoi"}})
+		assert.ErrorIs(t, err, ErrMissingDependency)
+	})
+
+	t.Run("修正セッションの2ターン目以降も参照シートを送る", func(t *testing.T) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec,
+			WithCharacterRegistry(newTestCharacterRegistry(t, &fakeAssets{})))
+		require.NoError(t, err)
+
+		session := g.NewRefinementSession(domain.ImagePageRequest{Prompt: "Aoi in the city", CharacterIDs: []string{"aoi"}})
+		_, err = session.Refine(ctx, "")
+		require.NoError(t, err)
+		_, err = session.Refine(ctx, "make it night")
+		require.NoError(t, err)
+
+		require.NotNil(t, exec.lastParts[0].FileData)
+		assert.Equal(t, "files/gs://sheets/aoi-front.png", exec.lastParts[0].FileData.FileURI)
+		assert.Contains(t, exec.lastParts[2].Text, "[Characters]")
+	})
+}
@@ -0,0 +1,136 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/gemini-image-kit/pkg/imgutil"
+)
+
+// supportedAspectRatios は Gemini の画像生成で指定できるアスペクト比です。
+var supportedAspectRatios = []struct {
+	name string
+	w, h float64
+}{
+	{"1:1", 1, 1}, {"2:3", 2, 3}, {"3:2", 3, 2}, {"3:4", 3, 4}, {"4:3", 4, 3},
+	{"4:5", 4, 5}, {"5:4", 5, 4}, {"9:16", 9, 16}, {"16:9", 16, 9}, {"21:9", 21, 9},
+}
+
+// nearestAspectRatio は w x h に最も近い、生成で指定可能なアスペクト比を返します。
+func nearestAspectRatio(w, h int) string {
+	target := math.Log(float64(w) / float64(h))
+	best, bestDiff := supportedAspectRatios[0].name, math.Inf(1)
+	for _, ar := range supportedAspectRatios {
+		if diff := math.Abs(math.Log(ar.w/ar.h) - target); diff < bestDiff {
+			best, bestDiff = ar.name, diff
+		}
+	}
+	return best
+}
+
+// ComposedPage は PageComposer で合成したページです。
+type ComposedPage struct {
+	Data     []byte                  // 裁ち落としを含むページ全体の PNG
+	MimeType string                  // 常に "image/png"
+	Panels   []*domain.ImageResponse // 読み順に並べた各コマの生成結果
+	Areas    []image.Rectangle       // 読み順に並べた各コマのキャンバス上の描画領域
+}
+
+// PageComposer はコマ割りのレイアウトに従ってコマを1枚ずつ生成し、ローカルで1ページに合成します。
+// ページ全体を1回で描かせる GenerateMangaPage と異なり、枠線・コマ順・余白が正確に保たれます。
+type PageComposer struct {
+	gen        *GeminiGenerator
+	background color.Color
+	border     color.Color
+}
+
+// NewPageComposer は新しい PageComposer を作成します。背景は白、枠線は黒で描画されます。
+func NewPageComposer(gen *GeminiGenerator) (*PageComposer, error) {
+	if gen == nil {
+		return nil, fmt.Errorf("%w: generator is required", ErrMissingDependency)
+	}
+	return &PageComposer{gen: gen, background: color.White, border: color.Black}, nil
+}
+
+// Compose は layout の各コマを生成して合成します。
+// panels[i] は読み順 (layout.OrderedPanels) で i 番目のコマのリクエストで、
+// AspectRatio はコマの形に最も近い値で上書きされます。
+// コマの生成は GenerateBatch で並列に行われ、1つでも失敗した場合はエラーを返します。
+func (c *PageComposer) Compose(ctx context.Context, layout domain.PageLayout, panels []domain.ImageGenerationRequest, opts BatchOptions) (*ComposedPage, error) {
+	if err := layout.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid page layout: %w", err)
+	}
+	ordered := layout.OrderedPanels()
+	if len(panels) != len(ordered) {
+		return nil, fmt.Errorf("layout has %d panels but %d requests were given", len(ordered), len(panels))
+	}
+
+	// 1. コマの形に合わせたアスペクト比で生成
+	areas := make([]image.Rectangle, len(ordered))
+	reqs := make([]domain.ImageGenerationRequest, len(panels))
+	for i, p := range ordered {
+		areas[i] = layout.PanelArea(p)
+		reqs[i] = panels[i]
+		reqs[i].AspectRatio = nearestAspectRatio(areas[i].Dx(), areas[i].Dy())
+	}
+
+	results, err := c.gen.GenerateBatch(ctx, reqs, opts)
+	if err != nil {
+		return nil, err
+	}
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("panel %d: %w", r.Index, r.Err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// 2. 裁ち落としを含むキャンバスに合成
+	canvas := image.NewNRGBA(image.Rect(0, 0, layout.Width+2*layout.Bleed, layout.Height+2*layout.Bleed))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(c.background), image.Point{}, draw.Src)
+
+	page := &ComposedPage{MimeType: imgutil.FormatPNG.MimeType(), Areas: areas}
+	for i, r := range results {
+		img, _, err := image.Decode(bytes.NewReader(r.Response.Data))
+		if err != nil {
+			return nil, fmt.Errorf("panel %d: %w: %w", i, ErrInvalidImage, err)
+		}
+		draw.Draw(canvas, areas[i], imgutil.CoverFit(img, areas[i].Dx(), areas[i].Dy()), image.Point{}, draw.Src)
+		c.drawBorder(canvas, areas[i], layout.BorderWidth)
+		page.Panels = append(page.Panels, r.Response)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode composed page: %w", err)
+	}
+	page.Data = buf.Bytes()
+	return page, nil
+}
+
+// drawBorder は area の内側に太さ width の枠線を描画します。
+func (c *PageComposer) drawBorder(dst draw.Image, area image.Rectangle, width int) {
+	if width <= 0 {
+		return
+	}
+	src := image.NewUniform(c.border)
+	for _, edge := range []image.Rectangle{
+		image.Rect(area.Min.X, area.Min.Y, area.Max.X, area.Min.Y+width),
+		image.Rect(area.Min.X, area.Max.Y-width, area.Max.X, area.Max.Y),
+		image.Rect(area.Min.X, area.Min.Y, area.Min.X+width, area.Max.Y),
+		image.Rect(area.Max.X-width, area.Min.Y, area.Max.X, area.Max.Y),
+	} {
+		draw.Draw(dst, edge.Intersect(area), src, image.Point{}, draw.Src)
+	}
+}
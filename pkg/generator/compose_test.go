@@ -0,0 +1,140 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"sync"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// composeExecutor はプロンプトごとに決まった単色の PNG を返す ImageExecutor のモックです。
+type composeExecutor struct {
+	mockExecutor
+	colors map[string]color.Color
+	fail   map[string]bool
+
+	mu           sync.Mutex
+	aspectRatios map[string]string
+}
+
+func (c *composeExecutor) ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error) {
+	prompt := parts[len(parts)-1].Text
+	c.mu.Lock()
+	c.aspectRatios[prompt] = opts.AspectRatio
+	c.mu.Unlock()
+	if c.fail[prompt] {
+		return nil, ErrSafetyBlocked
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, c.colors[prompt])
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return &domain.ImageResponse{Data: buf.Bytes(), MimeType: "image/png", Model: model}, nil
+}
+
+func TestNearestAspectRatio(t *testing.T) {
+	assert.Equal(t, "1:1", nearestAspectRatio(500, 510))
+	assert.Equal(t, "16:9", nearestAspectRatio(1920, 1000))
+	assert.Equal(t, "9:16", nearestAspectRatio(480, 1380))
+	assert.Equal(t, "21:9", nearestAspectRatio(3000, 900))
+}
+
+func TestPageComposer_Compose(t *testing.T) {
+	ctx := context.Background()
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+
+	layout := domain.PageLayout{
+		Width:       200,
+		Height:      100,
+		Bleed:       10,
+		Gutter:      10,
+		BorderWidth: 2,
+		Panels: []domain.PanelRect{
+			{X: 0, Y: 0, Width: 100, Height: 100},
+			{X: 100, Y: 0, Width: 100, Height: 100},
+		},
+	}
+
+	newComposer := func(t *testing.T, exec *composeExecutor) *PageComposer {
+		t.Helper()
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		require.NoError(t, err)
+		c, err := NewPageComposer(g)
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("読み順にコマを生成し、レイアウト通りに合成する", func(t *testing.T) {
+		exec := &composeExecutor{
+			colors:       map[string]color.Color{"first": red, "second": blue},
+			aspectRatios: make(map[string]string),
+		}
+		page, err := newComposer(t, exec).Compose(ctx, layout, []domain.ImageGenerationRequest{
+			{Prompt: "first"}, {Prompt: "second"},
+		}, BatchOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", page.MimeType)
+		assert.Len(t, page.Panels, 2)
+		assert.Equal(t, "4:5", exec.aspectRatios["first"], "the area includes the bleed on the outer edges")
+
+		img, err := png.Decode(bytes.NewReader(page.Data))
+		require.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 220, 120), img.Bounds(), "canvas includes bleed on every side")
+
+		// RTL なので最初のコマは右側に配置される
+		assert.Equal(t, image.Rect(115, 0, 220, 120), page.Areas[0])
+		assertColor(t, red, img.At(160, 60))
+		assertColor(t, blue, img.At(60, 60))
+		assertColor(t, color.Black, img.At(115, 60)) // 枠線
+		assertColor(t, color.White, img.At(110, 60)) // ガター
+		assertColor(t, blue, img.At(5, 5))           // 仕上がり線に接するコマは裁ち落としまで広がる
+	})
+
+	t.Run("コマ数とリクエスト数が一致しない場合はエラー", func(t *testing.T) {
+		exec := &composeExecutor{aspectRatios: make(map[string]string)}
+		_, err := newComposer(t, exec).Compose(ctx, layout, []domain.ImageGenerationRequest{{Prompt: "only"}}, BatchOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("不正なレイアウトはエラー", func(t *testing.T) {
+		exec := &composeExecutor{aspectRatios: make(map[string]string)}
+		_, err := newComposer(t, exec).Compose(ctx, domain.PageLayout{Width: 100, Height: 100}, nil, BatchOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("コマの生成に失敗した場合はそのコマを示すエラーを返す", func(t *testing.T) {
+		exec := &composeExecutor{
+			colors:       map[string]color.Color{"first": red},
+			fail:         map[string]bool{"second": true},
+			aspectRatios: make(map[string]string),
+		}
+		_, err := newComposer(t, exec).Compose(ctx, layout, []domain.ImageGenerationRequest{
+			{Prompt: "first"}, {Prompt: "second"},
+		}, BatchOptions{})
+		assert.ErrorIs(t, err, ErrSafetyBlocked)
+		assert.ErrorContains(t, err, "panel 1")
+	})
+}
+
+func assertColor(t *testing.T, want color.Color, got color.Color) {
+	t.Helper()
+	wr, wg, wb, _ := want.RGBA()
+	gr, gg, gb, _ := got.RGBA()
+	assert.Equal(t, [3]uint32{wr >> 8, wg >> 8, wb >> 8}, [3]uint32{gr >> 8, gg >> 8, gb >> 8})
+}
@@ -3,7 +3,6 @@ package generator
 import (
 	"context"
 	"fmt"
-	"net/http"
 	"path/filepath"
 	"time"
 
@@ -16,39 +15,71 @@ import (
 
 // GeminiImageCore は AssetManager と ImageExecutor の両方の責務を担う基盤クラスです。
 type GeminiImageCore struct {
-	aiClient   gemini.GenerativeModel
-	reader     remoteio.InputReader
-	httpClient httpkit.ClientInterface
-	cache      ImageCacher
-	expiration time.Duration
+	aiClient    gemini.GenerativeModel
+	reader      remoteio.InputReader
+	httpClient  httpkit.ClientInterface
+	cache       ImageCacher
+	expiration  time.Duration
+	compression imgutil.CompressionPolicy
+	localRoot   string
+	retry       RetryPolicy
+	limiter     RateLimiter
+}
+
+// CoreOption は GeminiImageCore の任意設定を適用する関数です。
+type CoreOption func(*GeminiImageCore)
+
+// WithCompressionPolicy は送信前の画像最適化ポリシーを設定します。
+// 未指定の場合は imgutil.DefaultCompressionPolicy が使用されます。
+// エンコードできない出力フォーマットを指定した場合、NewGeminiImageCore がエラーを返します。
+func WithCompressionPolicy(p imgutil.CompressionPolicy) CoreOption {
+	return func(c *GeminiImageCore) {
+		c.compression = p
+	}
+}
+
+// WithLocalRoot はローカルファイル (file:// またはパス) の参照を root 配下に限定して許可します。
+// 未指定の場合、ローカルファイルの参照は拒否されます。
+func WithLocalRoot(root string) CoreOption {
+	return func(c *GeminiImageCore) {
+		c.localRoot = root
+	}
 }
 
 // NewGeminiImageCore は依存関係を注入して GeminiImageCore を初期化します。
-func NewGeminiImageCore(aiClient gemini.GenerativeModel, reader remoteio.InputReader, httpClient httpkit.ClientInterface, cache ImageCacher, cacheTTL time.Duration) (*GeminiImageCore, error) {
+func NewGeminiImageCore(aiClient gemini.GenerativeModel, reader remoteio.InputReader, httpClient httpkit.ClientInterface, cache ImageCacher, cacheTTL time.Duration, opts ...CoreOption) (*GeminiImageCore, error) {
 	// どの依存関係が不足しているか具体的に示すように修正
 	if aiClient == nil {
-		return nil, fmt.Errorf("aiClient is required")
+		return nil, fmt.Errorf("%w: aiClient is required", ErrMissingDependency)
 	}
 	if reader == nil {
-		return nil, fmt.Errorf("reader is required")
+		return nil, fmt.Errorf("%w: reader is required", ErrMissingDependency)
 	}
 	if httpClient == nil {
-		return nil, fmt.Errorf("httpClient is required")
+		return nil, fmt.Errorf("%w: httpClient is required", ErrMissingDependency)
 	}
 	// cache は nil を許容（キャッシュなし動作）
 
-	return &GeminiImageCore{
-		aiClient:   aiClient,
-		reader:     reader,
-		httpClient: httpClient,
-		cache:      cache,
-		expiration: cacheTTL,
-	}, nil
+	c := &GeminiImageCore{
+		aiClient:    aiClient,
+		reader:      reader,
+		httpClient:  httpClient,
+		cache:       cache,
+		expiration:  cacheTTL,
+		compression: imgutil.DefaultCompressionPolicy(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if err := c.compression.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid compression policy: %w", err)
+	}
+	return c, nil
 }
 
 // UploadFile は画像を Gemini File API にアップロードし、URI を返します。
 func (c *GeminiImageCore) UploadFile(ctx context.Context, fileURI string) (string, error) {
-	cacheKeyURI := cacheKeyFileAPIURI + fileURI
+	cacheKeyURI := cacheKeyFileAPIURI + sourceKey(fileURI)
 	if c.cache != nil {
 		if val, ok := c.cache.Get(cacheKeyURI); ok {
 			if uri, ok := val.(string); ok {
@@ -62,15 +93,19 @@ func (c *GeminiImageCore) UploadFile(ctx context.Context, fileURI string) (strin
 		return "", err
 	}
 
-	finalData := data
-	if UseImageCompression {
-		if compressed, err := imgutil.CompressToJPEG(data, ImageCompressionQuality); err == nil {
-			finalData = compressed
-		}
+	finalData, err := c.optimize(data)
+	if err != nil {
+		return "", err
 	}
-
-	mimeType := http.DetectContentType(finalData)
+	format, err := imgutil.DetectFormat(finalData)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	mimeType := format.MimeType()
 	displayName := filepath.Base(fileURI)
+	if isDataURI(fileURI) {
+		displayName = "inline-image"
+	}
 
 	// File API へのアップロード
 	uri, fileName, err := c.aiClient.UploadFile(ctx, finalData, mimeType, displayName)
@@ -81,7 +116,7 @@ func (c *GeminiImageCore) UploadFile(ctx context.Context, fileURI string) (strin
 	// URI（参照用）と Name（削除用）の両方をキャッシュ
 	if c.cache != nil {
 		c.cache.Set(cacheKeyURI, uri, c.expiration)
-		c.cache.Set(cacheKeyFileAPIName+fileURI, fileName, c.expiration)
+		c.cache.Set(cacheKeyFileAPIName+sourceKey(fileURI), fileName, c.expiration)
 	}
 
 	return uri, nil
@@ -90,7 +125,7 @@ func (c *GeminiImageCore) UploadFile(ctx context.Context, fileURI string) (strin
 // DeleteFile はキャッシュされたファイル名を使用して Gemini File API からファイルを削除します。
 func (c *GeminiImageCore) DeleteFile(ctx context.Context, fileURI string) error {
 	if c.cache != nil {
-		if val, ok := c.cache.Get(cacheKeyFileAPIName + fileURI); ok {
+		if val, ok := c.cache.Get(cacheKeyFileAPIName + sourceKey(fileURI)); ok {
 			if name, ok := val.(string); ok {
 				// 正しいファイル名 (files/xxxx) で削除を実行
 				return c.aiClient.DeleteFile(ctx, name)
@@ -99,5 +134,37 @@ func (c *GeminiImageCore) DeleteFile(ctx context.Context, fileURI string) error
 	}
 
 	// キャッシュミスした場合、URL 形式の fileURI では Delete API を叩けないためエラーを返す
-	return fmt.Errorf("cannot determine file name for deletion, file not found in cache: %s", fileURI)
+	return fmt.Errorf("cannot determine file name for deletion, file not found in cache: %s", sourceKey(fileURI))
+}
+
+// optimize は圧縮ポリシーを適用したうえで、EXIF の向き補正とメタデータ除去を行います。
+// 再エンコードする場合は Compress が向きを反映するため、非可逆な再エンコードは1回のみです。
+// 圧縮またはメタデータの除去に失敗した場合は、元のデータを送信せずにエラーを返します。
+func (c *GeminiImageCore) optimize(data []byte) ([]byte, error) {
+	return optimizeWith(data, c.compression)
+}
+
+// maskPolicy は編集用マスクの圧縮ポリシーを返します。
+// 境界がにじまないよう常に PNG で出力し、縮小は編集元の画像と同じ上限に揃えます。
+func (c *GeminiImageCore) maskPolicy() imgutil.CompressionPolicy {
+	policy := imgutil.CompressionPolicy{Enabled: true, Format: imgutil.FormatPNG}
+	if c.compression.Enabled {
+		policy.MaxWidth = c.compression.MaxWidth
+		policy.MaxHeight = c.compression.MaxHeight
+	}
+	return policy
+}
+
+// optimizeWith は指定したポリシーで optimize と同じ処理を行います。
+func optimizeWith(data []byte, policy imgutil.CompressionPolicy) ([]byte, error) {
+	data, err := imgutil.Compress(data, policy)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+
+	normalized, err := imgutil.Normalize(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to strip metadata: %w", ErrInvalidImage, err)
+	}
+	return normalized, nil
 }
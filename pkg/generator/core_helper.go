@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net/http"
+	"log/slog"
+	"mime"
 	"strings"
+	"time"
 
 	"github.com/shouni/gemini-image-kit/pkg/domain"
 	"github.com/shouni/gemini-image-kit/pkg/imgutil"
@@ -15,31 +17,132 @@ import (
 )
 
 // ExecuteRequest は Gemini API を呼び出し、レスポンスをパースします。(ImageExecutor インターフェース実装)
-func (c *GeminiImageCore) ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts gemini.GenerateOptions) (*domain.ImageResponse, error) {
-	resp, err := c.aiClient.GenerateWithParts(ctx, model, parts, opts)
-	if err != nil {
+// RetryPolicy が設定されている場合は一時的なエラーを再試行し、全ての試行を ImageResponse.Attempts に記録します。
+// API 呼び出し後に失敗した場合は、試行の記録を *RetryError で返します。
+// RateLimiter が設定されている場合は、各呼び出しの前に送信枠を確保します。
+func (c *GeminiImageCore) ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error) {
+	if err := c.checkOptions(opts); err != nil {
 		return nil, err
 	}
 
+	var attempts []domain.GenerationAttempt
+	var delay time.Duration
+
+	for n := 1; ; n++ {
+		if n > 1 && c.retry.ReseedOnRetry {
+			seed := newRetrySeed()
+			opts.Seed = &seed
+		}
+
+		// クライアント側の上限は再試行の対象にしない
+		if c.limiter != nil {
+			if err := c.limiter.Acquire(ctx, model, requestedImages(opts)); err != nil {
+				if len(attempts) > 0 {
+					return nil, &RetryError{Attempts: attempts, Err: err}
+				}
+				return nil, err
+			}
+		}
+
+		resp, err := c.executeOnce(ctx, model, parts, opts)
+		attempt := domain.GenerationAttempt{Number: n, Seed: domain.DereferenceSeed(opts.Seed), Delay: delay}
+		if err == nil {
+			resp.Attempts = append(attempts, attempt)
+			return resp, nil
+		}
+		attempt.Err = err.Error()
+		attempts = append(attempts, attempt)
+
+		if n >= c.retry.MaxAttempts || !c.retry.shouldRetry(err) {
+			return nil, &RetryError{Attempts: attempts, Err: err}
+		}
+
+		delay = c.retry.backoff(n, err)
+		slog.WarnContext(ctx, "画像生成に失敗したため再試行します", "model", model, "attempt", n, "delay", delay, "error", err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, &RetryError{Attempts: attempts, Err: err}
+		}
+	}
+}
+
+// executeOnce は API を1回呼び出し、分類済みのエラーまたはレスポンスを返します。
+func (c *GeminiImageCore) executeOnce(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error) {
+	resp, err := c.generateContent(ctx, model, parts, opts)
+	if err != nil {
+		return nil, classifyClientError(err)
+	}
+
 	out, err := c.ParseToResponse(resp, domain.DereferenceSeed(opts.Seed))
 	if err != nil {
 		return nil, err
 	}
+	return newImageResponse(out, model), nil
+}
+
+// generateContent は aiClient が ContentModel を実装している場合は全ての生成パラメータを送信し、
+// そうでない場合は GenerateWithParts を呼び出します。
+func (c *GeminiImageCore) generateContent(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*gemini.Response, error) {
+	if cm, ok := c.aiClient.(ContentModel); ok {
+		raw, err := cm.GenerateContentWithConfig(ctx, model, parts, contentConfig(opts))
+		if err != nil {
+			return nil, err
+		}
+		return &gemini.Response{RawResponse: raw}, nil
+	}
+	return c.aiClient.GenerateWithParts(ctx, model, parts, opts.clientOptions())
+}
+
+// checkOptions は aiClient が送信できない生成パラメータが指定されていないかを検証します。
+// go-gemini-client の GenerateWithParts は CandidateCount・Temperature・TopP をクライアントの設定値で上書きし、
+// TopK と ResponseMIMEType には対応していないため、黙って無視せずにエラーとします。
+func (c *GeminiImageCore) checkOptions(opts GenerateOptions) error {
+	if _, ok := c.aiClient.(ContentModel); ok {
+		return nil
+	}
+	var unsupported []string
+	if opts.CandidateCount != nil && *opts.CandidateCount > 1 {
+		unsupported = append(unsupported, "CandidateCount")
+	}
+	if opts.Temperature != nil {
+		unsupported = append(unsupported, "Temperature")
+	}
+	if opts.TopP != nil {
+		unsupported = append(unsupported, "TopP")
+	}
+	if opts.TopK != nil {
+		unsupported = append(unsupported, "TopK")
+	}
+	if opts.ResponseMIMEType != "" {
+		unsupported = append(unsupported, "ResponseMIMEType")
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("%w: %s requires a ContentModel client such as GenAIClient", ErrUnsupportedOption, strings.Join(unsupported, ", "))
+	}
+	return nil
+}
 
+// newImageResponse は解析結果と使用モデルからドメインのレスポンスを組み立てます。
+func newImageResponse(out *ImageOutput, model string) *domain.ImageResponse {
 	return &domain.ImageResponse{
-		Data:     out.Data,
-		MimeType: out.MimeType,
-		UsedSeed: out.UsedSeed,
-	}, nil
+		Data:          out.Data,
+		MimeType:      out.MimeType,
+		UsedSeed:      out.UsedSeed,
+		Images:        out.Images,
+		Text:          out.Text,
+		SafetyRatings: out.SafetyRatings,
+		Usage:         out.Usage,
+		Model:         model,
+		ModelVersion:  out.ModelVersion,
+	}
 }
 
 // PrepareImagePart は URL または cloud storageから画像を準備し、genai.Part に変換します。(ImageExecutor インターフェース実装)
-func (c *GeminiImageCore) PrepareImagePart(ctx context.Context, rawURL string) *genai.Part {
+func (c *GeminiImageCore) PrepareImagePart(ctx context.Context, rawURL string) (*genai.Part, error) {
 	// 1. File API キャッシュチェック
 	if c.cache != nil {
-		if val, ok := c.cache.Get(cacheKeyFileAPIURI + rawURL); ok {
+		if val, ok := c.cache.Get(cacheKeyFileAPIURI + sourceKey(rawURL)); ok {
 			if uri, ok := val.(string); ok {
-				return &genai.Part{FileData: &genai.FileData{FileURI: uri}}
+				return &genai.Part{FileData: &genai.FileData{FileURI: uri}}, nil
 			}
 		}
 	}
@@ -47,74 +150,262 @@ func (c *GeminiImageCore) PrepareImagePart(ctx context.Context, rawURL string) *
 	// 2. 画像の取得と圧縮
 	data, err := c.fetchImageData(ctx, rawURL)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	optimized, err := c.optimize(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.toPart(optimized)
+}
+
+// PrepareInlinePart はメモリ上の画像データを最適化し、genai.Part に変換します。(ImageExecutor インターフェース実装)
+// mimeType が指定されている場合は、実際のデータの形式と一致するかを検証します。
+func (c *GeminiImageCore) PrepareInlinePart(ctx context.Context, data []byte, mimeType string) (*genai.Part, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty data", ErrInvalidImage)
+	}
+	if err := checkMimeType(data, mimeType); err != nil {
+		return nil, err
+	}
+	optimized, err := c.optimize(data)
+	if err != nil {
+		return nil, err
 	}
+	return c.toPart(optimized)
+}
 
-	finalData := data
-	if UseImageCompression {
-		if compressed, err := imgutil.CompressToJPEG(data, ImageCompressionQuality); err == nil {
-			finalData = compressed
+// PrepareMaskPart は編集用のマスク画像を可逆な PNG の画像パーツに変換します。(MaskPreparer インターフェース実装)
+// 非可逆圧縮で白黒の境界がにじまないよう、圧縮ポリシーに関わらず JPEG には変換しません。
+func (c *GeminiImageCore) PrepareMaskPart(ctx context.Context, uri domain.ImageURI) (*genai.Part, error) {
+	var data []byte
+	switch {
+	case len(uri.Data) > 0:
+		if err := checkMimeType(uri.Data, uri.MimeType); err != nil {
+			return nil, err
+		}
+		data = uri.Data
+	case uri.ReferenceURL != "":
+		fetched, err := c.fetchImageData(ctx, uri.ReferenceURL)
+		if err != nil {
+			return nil, err
 		}
+		data = fetched
+	default:
+		return nil, fmt.Errorf("%w: empty data", ErrInvalidImage)
 	}
 
-	return c.toPart(finalData)
+	optimized, err := optimizeWith(data, c.maskPolicy())
+	if err != nil {
+		return nil, err
+	}
+	return c.toPart(optimized)
+}
+
+// checkMimeType は mimeType が指定されている場合に、実際のデータの形式と一致するかを検証します。
+func checkMimeType(data []byte, mimeType string) error {
+	if mimeType == "" {
+		return nil
+	}
+	format, err := imgutil.DetectFormat(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidImage, err)
+	}
+	if format.MimeType() != normalizeMimeType(mimeType) {
+		return fmt.Errorf("%w: declared %s but detected %s", ErrInvalidImage, mimeType, format.MimeType())
+	}
+	return nil
+}
+
+// normalizeMimeType は宣言された MIME タイプを DetectFormat の表記に揃えます。
+// 大文字小文字とパラメータ (";charset=..." 等) を無視し、非標準の image/jpg を image/jpeg として扱います。
+func normalizeMimeType(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mediaType
+	}
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if mimeType == "image/jpg" || mimeType == "image/pjpeg" {
+		return "image/jpeg"
+	}
+	return mimeType
 }
 
 // fetchImageData は、指定されたURLまたはcloud storageから画像データを取得します。
-// URLの安全性を検証し、cloud storageまたはHTTP経由でデータをフェッチします。
+// data: URI、cloud storage、ローカルファイル (許可されたルート配下のみ)、HTTP の順に判定します。
 func (c *GeminiImageCore) fetchImageData(ctx context.Context, rawURL string) ([]byte, error) {
-	// 1. cloud storageの場合
+	// 1. data: URI の場合
+	if isDataURI(rawURL) {
+		data, err := decodeDataURI(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrAssetFetch, err)
+		}
+		return data, nil
+	}
+
+	// 2. cloud storageの場合
 	if remoteio.IsRemoteURI(rawURL) {
 		rc, err := c.reader.Open(ctx, rawURL)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %s: %w", ErrAssetFetch, rawURL, err)
 		}
 		defer rc.Close()
-		return io.ReadAll(rc)
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrAssetFetch, rawURL, err)
+		}
+		return data, nil
 	}
 
-	// 2. HTTP/HTTPSの場合
+	// 3. ローカルファイルの場合
+	if isLocalPath(rawURL) {
+		data, err := readLocalFile(c.localRoot, rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrAssetFetch, rawURL, err)
+		}
+		return data, nil
+	}
+
+	// 4. HTTP/HTTPSの場合
 	// httpClient (httpkit.Client) 内部で SkipNetworkValidation フラグに基づいた
 	// 安全検証が行われるため、ここではそのまま呼び出すだけでOK。
-	return c.httpClient.FetchBytes(ctx, rawURL)
+	data, err := c.httpClient.FetchBytes(ctx, rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrAssetFetch, rawURL, err)
+	}
+	return data, nil
 }
 
-// toPart は、与えられたデータが有効な画像MIMEタイプを持つ場合に genai.Part オブジェクトへ変換します。
-// 画像でない場合は nil を返します。
-func (c *GeminiImageCore) toPart(data []byte) *genai.Part {
-	mimeType := http.DetectContentType(data)
-	if !strings.HasPrefix(mimeType, "image/") {
-		return nil
+// toPart は、与えられたデータが対応する画像フォーマットの場合に genai.Part オブジェクトへ変換します。
+// 画像でない場合や未対応のフォーマットの場合は ErrInvalidImage を返します。
+func (c *GeminiImageCore) toPart(data []byte) (*genai.Part, error) {
+	format, err := imgutil.DetectFormat(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImage, err)
 	}
-	return &genai.Part{InlineData: &genai.Blob{MIMEType: mimeType, Data: data}}
+	return &genai.Part{InlineData: &genai.Blob{MIMEType: format.MimeType(), Data: data}}, nil
 }
 
-// ParseToResponse は Gemini からのレスポンスを検証し、画像データを抽出します。
+// ParseToResponse は Gemini からのレスポンスを検証し、全候補から画像データを抽出します。
+// 一部の候補が中断されていても、他の候補に画像があればそれらを返します。
 func (c *GeminiImageCore) ParseToResponse(resp *gemini.Response, seed int64) (*ImageOutput, error) {
-	if resp == nil || resp.RawResponse == nil || len(resp.RawResponse.Candidates) == 0 {
-		return nil, fmt.Errorf("invalid or empty response from Gemini")
+	if resp == nil || resp.RawResponse == nil {
+		return nil, ErrEmptyResponse
+	}
+
+	raw := resp.RawResponse
+	if len(raw.Candidates) == 0 {
+		// 候補が1件もない場合は、プロンプト自体がブロックされている可能性がある
+		if raw.PromptFeedback != nil && raw.PromptFeedback.BlockReason != "" {
+			return nil, newPromptBlockedError(raw.PromptFeedback)
+		}
+		return nil, ErrEmptyResponse
 	}
 
-	candidate := resp.RawResponse.Candidates[0]
+	var images []domain.GeneratedImage
+	var texts []string
+	var ratings []domain.SafetyRating
+	var firstErr *GenerationError
+
+	for i, candidate := range raw.Candidates {
+		if candidate == nil {
+			continue
+		}
+
+		// FinishReasonの検証: 安全フィルターによるブロックや中断を正しくハンドリングする
+		if candidate.FinishReason != genai.FinishReasonStop && candidate.FinishReason != genai.FinishReasonUnspecified {
+			if firstErr == nil {
+				firstErr = newCandidateError(candidate, raw.PromptFeedback)
+			}
+			continue
+		}
 
-	// FinishReasonの検証: 安全フィルターによるブロックや中断を正しくハンドリングする
-	if candidate.FinishReason != genai.FinishReasonStop && candidate.FinishReason != genai.FinishReasonUnspecified {
-		return nil, fmt.Errorf("generation failed with FinishReason: %s", candidate.FinishReason)
+		if candidate.Content == nil {
+			if firstErr == nil {
+				firstErr = &GenerationError{
+					Kind:          ErrNoImageInResponse,
+					FinishReason:  candidate.FinishReason,
+					SafetyRatings: candidate.SafetyRatings,
+				}
+			}
+			continue
+		}
+
+		found := len(images)
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.Thought:
+				// 思考過程のテキストや途中画像は結果に含めない
+			case part.InlineData != nil:
+				images = append(images, domain.GeneratedImage{
+					Data:           part.InlineData.Data,
+					MimeType:       part.InlineData.MIMEType,
+					CandidateIndex: i,
+				})
+			case part.Text != "":
+				texts = append(texts, part.Text)
+			}
+		}
+
+		// 安全性評価は最初に画像を返した候補のものを採用する
+		if ratings == nil && len(images) > found {
+			ratings = toDomainSafetyRatings(candidate.SafetyRatings)
+		}
 	}
 
-	if candidate.Content == nil {
-		return nil, fmt.Errorf("no content found in candidate")
+	if len(images) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		candidate := raw.Candidates[0]
+		return nil, &GenerationError{
+			Kind:           ErrNoImageInResponse,
+			FinishReason:   candidate.FinishReason,
+			SafetyRatings:  candidate.SafetyRatings,
+			PromptFeedback: raw.PromptFeedback,
+		}
 	}
 
-	for _, part := range candidate.Content.Parts {
-		if part.InlineData != nil {
-			return &ImageOutput{
-				Data:     part.InlineData.Data,
-				MimeType: part.InlineData.MIMEType,
-				UsedSeed: seed,
-			}, nil
+	return &ImageOutput{
+		Data:          images[0].Data,
+		MimeType:      images[0].MimeType,
+		UsedSeed:      seed,
+		Images:        images,
+		Text:          strings.Join(texts, "\n"),
+		SafetyRatings: ratings,
+		Usage:         toDomainUsage(raw.UsageMetadata),
+		ModelVersion:  raw.ModelVersion,
+	}, nil
+}
+
+// toDomainSafetyRatings は SDK の安全性評価をドメインモデルに変換します。
+func toDomainSafetyRatings(src []*genai.SafetyRating) []domain.SafetyRating {
+	if len(src) == 0 {
+		return nil
+	}
+	ratings := make([]domain.SafetyRating, 0, len(src))
+	for _, r := range src {
+		if r == nil {
+			continue
 		}
+		ratings = append(ratings, domain.SafetyRating{
+			Category:    string(r.Category),
+			Probability: string(r.Probability),
+			Blocked:     r.Blocked,
+		})
 	}
+	return ratings
+}
 
-	return nil, fmt.Errorf("no image data found in response parts")
+// toDomainUsage は SDK のトークン使用量をドメインモデルに変換します。
+func toDomainUsage(src *genai.GenerateContentResponseUsageMetadata) *domain.TokenUsage {
+	if src == nil {
+		return nil
+	}
+	return &domain.TokenUsage{
+		PromptTokens:     src.PromptTokenCount,
+		CandidatesTokens: src.CandidatesTokenCount,
+		ThoughtsTokens:   src.ThoughtsTokenCount,
+		TotalTokens:      src.TotalTokenCount,
+	}
 }
@@ -1,7 +1,11 @@
 package generator
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"image"
+	"image/jpeg"
 	"testing"
 	"time"
 
@@ -26,8 +30,11 @@ func TestGeminiImageCore_PrepareImagePart(t *testing.T) {
 		cache.Set(cacheKeyFileAPIURI+rawURL, fileURI, time.Hour)
 
 		// メソッド名を大文字に変更
-		part := core.PrepareImagePart(ctx, rawURL)
+		part, err := core.PrepareImagePart(ctx, rawURL)
 
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
 		if part == nil || part.FileData == nil {
 			t.Fatal("expected FileData part, got nil or other")
 		}
@@ -36,12 +43,25 @@ func TestGeminiImageCore_PrepareImagePart(t *testing.T) {
 		}
 	})
 
-	t.Run("不正なURLはnilを返す(fetchImageData内のIsSafeURLで失敗)", func(t *testing.T) {
+	t.Run("不正なURLはエラーを返す(fetchImageData内のIsSafeURLで失敗)", func(t *testing.T) {
 		// ローカルホスト等は IsSafeURL で false になる想定
-		part := core.PrepareImagePart(ctx, "http://127.0.0.1/evil.png")
+		part, err := core.PrepareImagePart(ctx, "http://127.0.0.1/evil.png")
 		if part != nil {
 			t.Error("expected nil for unsafe URL")
 		}
+		if !errors.Is(err, ErrAssetFetch) {
+			t.Errorf("expected ErrAssetFetch, got %v", err)
+		}
+	})
+
+	t.Run("画像以外のデータはエラーを返す", func(t *testing.T) {
+		part, err := core.PrepareImagePart(ctx, "https://example.com/not-image.txt")
+		if part != nil {
+			t.Error("expected nil for non-image data")
+		}
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("expected ErrInvalidImage, got %v", err)
+		}
 	})
 }
 
@@ -80,6 +100,135 @@ func TestGeminiImageCore_ParseToResponse(t *testing.T) {
 		}
 	})
 
+	t.Run("正常系: 複数候補の画像をすべて返す", func(t *testing.T) {
+		resp := &gemini.Response{
+			RawResponse: &genai.GenerateContentResponse{
+				Candidates: []*genai.Candidate{
+					{
+						FinishReason: genai.FinishReasonStop,
+						Content: &genai.Content{Parts: []*genai.Part{
+							{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("first")}},
+						}},
+					},
+					{
+						FinishReason: genai.FinishReasonSafety,
+						Content:      &genai.Content{Parts: []*genai.Part{}},
+					},
+					{
+						FinishReason: genai.FinishReasonStop,
+						Content: &genai.Content{Parts: []*genai.Part{
+							{InlineData: &genai.Blob{MIMEType: "image/jpeg", Data: []byte("third")}},
+						}},
+					},
+				},
+			},
+		}
+
+		out, err := core.ParseToResponse(resp, seed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(out.Images) != 2 {
+			t.Fatalf("expected 2 images, got %d", len(out.Images))
+		}
+		if string(out.Data) != "first" {
+			t.Errorf("Data should be the first image, got %q", out.Data)
+		}
+		if out.Images[1].CandidateIndex != 2 || out.Images[1].MimeType != "image/jpeg" {
+			t.Errorf("second image mismatch: %+v", out.Images[1])
+		}
+	})
+
+	t.Run("正常系: テキスト・安全性評価・使用量を併せて返す", func(t *testing.T) {
+		resp := &gemini.Response{
+			RawResponse: &genai.GenerateContentResponse{
+				ModelVersion: "gemini-test-001",
+				UsageMetadata: &genai.GenerateContentResponseUsageMetadata{
+					PromptTokenCount:     10,
+					CandidatesTokenCount: 1290,
+					TotalTokenCount:      1300,
+				},
+				Candidates: []*genai.Candidate{
+					{
+						FinishReason: genai.FinishReasonStop,
+						SafetyRatings: []*genai.SafetyRating{
+							{Category: genai.HarmCategoryHarassment, Probability: genai.HarmProbabilityNegligible},
+						},
+						Content: &genai.Content{Parts: []*genai.Part{
+							{Text: "thinking...", Thought: true},
+							{Text: "Here is your panel."},
+							{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("png-data")}},
+						}},
+					},
+				},
+			},
+		}
+
+		out, err := core.ParseToResponse(resp, seed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if out.Text != "Here is your panel." {
+			t.Errorf("Text mismatch: %q", out.Text)
+		}
+		if len(out.SafetyRatings) != 1 || out.SafetyRatings[0].Category != string(genai.HarmCategoryHarassment) {
+			t.Errorf("SafetyRatings mismatch: %+v", out.SafetyRatings)
+		}
+		if out.Usage == nil || out.Usage.TotalTokens != 1300 {
+			t.Errorf("Usage mismatch: %+v", out.Usage)
+		}
+		if out.ModelVersion != "gemini-test-001" {
+			t.Errorf("ModelVersion mismatch: %s", out.ModelVersion)
+		}
+	})
+
+	t.Run("正常系: 思考中の途中画像は結果に含めない", func(t *testing.T) {
+		resp := &gemini.Response{
+			RawResponse: &genai.GenerateContentResponse{
+				Candidates: []*genai.Candidate{
+					{
+						FinishReason: genai.FinishReasonStop,
+						Content: &genai.Content{Parts: []*genai.Part{
+							{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("draft")}, Thought: true},
+							{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("final")}},
+						}},
+					},
+				},
+			},
+		}
+
+		out, err := core.ParseToResponse(resp, seed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(out.Images) != 1 {
+			t.Fatalf("expected 1 image, got %d", len(out.Images))
+		}
+		if string(out.Data) != "final" {
+			t.Errorf("Data should be the final image, got %q", out.Data)
+		}
+	})
+
+	t.Run("異常系: 思考中の途中画像しかない場合は画像なしとして扱う", func(t *testing.T) {
+		resp := &gemini.Response{
+			RawResponse: &genai.GenerateContentResponse{
+				Candidates: []*genai.Candidate{
+					{
+						FinishReason: genai.FinishReasonStop,
+						Content: &genai.Content{Parts: []*genai.Part{
+							{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("draft")}, Thought: true},
+						}},
+					},
+				},
+			},
+		}
+
+		_, err := core.ParseToResponse(resp, seed)
+		if !errors.Is(err, ErrNoImageInResponse) {
+			t.Errorf("expected ErrNoImageInResponse, got %v", err)
+		}
+	})
+
 	t.Run("異常系: FinishReasonSafety によるブロック", func(t *testing.T) {
 		resp := &gemini.Response{
 			RawResponse: &genai.GenerateContentResponse{
@@ -93,10 +242,14 @@ func TestGeminiImageCore_ParseToResponse(t *testing.T) {
 		}
 		_, err := core.ParseToResponse(resp, seed)
 		if err == nil {
-			t.Error("expected error for Safety block")
+			t.Fatal("expected error for Safety block")
+		}
+		if !errors.Is(err, ErrSafetyBlocked) {
+			t.Errorf("expected ErrSafetyBlocked, got %v", err)
 		}
-		if err != nil && !testing.Short() {
-			t.Logf("expected error message: %v", err)
+		var genErr *GenerationError
+		if !errors.As(err, &genErr) || genErr.FinishReason != genai.FinishReasonSafety {
+			t.Errorf("expected GenerationError with FinishReason SAFETY, got %v", err)
 		}
 	})
 
@@ -112,15 +265,93 @@ func TestGeminiImageCore_ParseToResponse(t *testing.T) {
 			},
 		}
 		_, err := core.ParseToResponse(resp, seed)
-		if err == nil {
-			t.Error("expected error for text-only response")
+		if !errors.Is(err, ErrNoImageInResponse) {
+			t.Errorf("expected ErrNoImageInResponse, got %v", err)
 		}
 	})
 
 	t.Run("異常系: 空のレスポンス", func(t *testing.T) {
 		_, err := core.ParseToResponse(nil, seed)
-		if err == nil {
-			t.Error("expected error for nil response")
+		if !errors.Is(err, ErrEmptyResponse) {
+			t.Errorf("expected ErrEmptyResponse, got %v", err)
+		}
+	})
+
+	t.Run("異常系: プロンプト自体のブロック", func(t *testing.T) {
+		resp := &gemini.Response{
+			RawResponse: &genai.GenerateContentResponse{
+				PromptFeedback: &genai.GenerateContentResponsePromptFeedback{
+					BlockReason: genai.BlockedReasonSafety,
+				},
+			},
+		}
+		_, err := core.ParseToResponse(resp, seed)
+		if !errors.Is(err, ErrSafetyBlocked) {
+			t.Errorf("expected ErrSafetyBlocked, got %v", err)
+		}
+		var genErr *GenerationError
+		if !errors.As(err, &genErr) || genErr.PromptFeedback == nil {
+			t.Errorf("expected PromptFeedback to be attached, got %v", err)
+		}
+	})
+}
+
+// ExecuteRequest のテスト
+func TestGeminiImageCore_ExecuteRequest(t *testing.T) {
+	core := &GeminiImageCore{aiClient: &mockAIClient{}}
+	seed := int64(7)
+
+	resp, err := core.ExecuteRequest(context.Background(), "test-model", []*genai.Part{{Text: "prompt"}}, GenerateOptions{Seed: &seed})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Model != "test-model" {
+		t.Errorf("Model should be recorded, got %q", resp.Model)
+	}
+	if resp.UsedSeed != seed || len(resp.Images) != 1 {
+		t.Errorf("response mismatch: %+v", resp)
+	}
+}
+
+// PrepareInlinePart のテスト
+func TestGeminiImageCore_PrepareInlinePart(t *testing.T) {
+	ctx := context.Background()
+	core := &GeminiImageCore{}
+
+	t.Run("メモリ上の画像からパーツを作成する", func(t *testing.T) {
+		part, err := core.PrepareInlinePart(ctx, mockImagePNG, "image/png")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if part.InlineData == nil || part.InlineData.MIMEType != "image/png" {
+			t.Errorf("unexpected part: %+v", part)
+		}
+	})
+
+	t.Run("宣言された MIME タイプと内容が異なる場合はエラー", func(t *testing.T) {
+		_, err := core.PrepareInlinePart(ctx, mockImagePNG, "image/jpeg")
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("expected ErrInvalidImage, got %v", err)
+		}
+	})
+
+	t.Run("MIME タイプの表記揺れは同じ形式として扱う", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+			t.Fatal(err)
+		}
+		jpegData := buf.Bytes()
+		for _, declared := range []string{"image/jpg", "image/JPEG", "Image/Jpeg; charset=binary"} {
+			if _, err := core.PrepareInlinePart(ctx, jpegData, declared); err != nil {
+				t.Errorf("%q: unexpected error: %v", declared, err)
+			}
+		}
+	})
+
+	t.Run("空のデータはエラー", func(t *testing.T) {
+		_, err := core.PrepareInlinePart(ctx, nil, "")
+		if !errors.Is(err, ErrInvalidImage) {
+			t.Errorf("expected ErrInvalidImage, got %v", err)
 		}
 	})
 }
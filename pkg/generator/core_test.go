@@ -1,10 +1,18 @@
 package generator
 
 import (
+	"bytes"
 	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"testing"
 	"time"
 
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/gemini-image-kit/pkg/imgutil"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,7 +25,7 @@ func TestGeminiImageCore_UploadFile(t *testing.T) {
 	// mocks_test.go のモックを利用
 	cache := &mockCache{data: make(map[string]any)}
 	ai := &mockAIClient{}
-	httpMock := &mockHTTPClient{data: []byte("fake-image-binary")}
+	httpMock := &mockHTTPClient{data: mockImagePNG}
 	reader := &mockReader{}
 
 	core, err := NewGeminiImageCore(ai, reader, httpMock, cache, time.Hour)
@@ -43,6 +51,27 @@ func TestGeminiImageCore_UploadFile(t *testing.T) {
 		assert.Equal(t, uri, cachedURI)
 	})
 
+	t.Run("取得に失敗した場合は ErrAssetFetch を返す", func(t *testing.T) {
+		cache.Clear()
+		_, err := core.UploadFile(ctx, "http://127.0.0.1/secret.png")
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, ErrAssetFetch)
+	})
+
+	t.Run("HEIC 画像は未対応フォーマットとしてエラーを返す", func(t *testing.T) {
+		cache.Clear()
+		heic := append([]byte{0x00, 0x00, 0x00, 0x18}, []byte("ftypheic\x00\x00\x00\x00mif1heic")...)
+		heicCore, err := NewGeminiImageCore(ai, reader, &mockHTTPClient{data: heic}, cache, time.Hour)
+		require.NoError(t, err)
+
+		_, err = heicCore.UploadFile(ctx, "https://example.com/photo.heic")
+
+		require.Error(t, err)
+		assert.ErrorIs(t, err, imgutil.ErrUnsupportedFormat)
+		assert.ErrorIs(t, err, ErrInvalidImage)
+	})
+
 	t.Run("キャッシュがある場合はアップロードをスキップする", func(t *testing.T) {
 		ai.uploadCalled = false
 		fileURL := "https://example.com/cached.png"
@@ -91,3 +120,142 @@ func TestGeminiImageCore_DeleteFile(t *testing.T) {
 		assert.Contains(t, err.Error(), expectedErrMsg)
 	})
 }
+
+func TestNewGeminiImageCore_MissingDependency(t *testing.T) {
+	_, err := NewGeminiImageCore(nil, &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrMissingDependency)
+	assert.Contains(t, err.Error(), "aiClient is required")
+}
+
+func TestGeminiImageCore_CompressionPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(0, 0, color.NRGBA{255, 255, 255, 0})
+	buf := new(bytes.Buffer)
+	require.NoError(t, png.Encode(buf, img))
+	pngData := buf.Bytes()
+
+	t.Run("圧縮を無効化した場合は元データをアップロードする", func(t *testing.T) {
+		ai := &mockAIClient{}
+		core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{data: pngData}, nil, time.Hour,
+			WithCompressionPolicy(imgutil.CompressionPolicy{Enabled: false}))
+		require.NoError(t, err)
+
+		_, err = core.UploadFile(ctx, "https://example.com/line-art.png")
+		require.NoError(t, err)
+		assert.Equal(t, pngData, ai.lastUploadData)
+	})
+
+	t.Run("デフォルトポリシーでは透過PNGをPNGのまま送信する", func(t *testing.T) {
+		core, err := NewGeminiImageCore(&mockAIClient{}, &mockReader{}, &mockHTTPClient{data: pngData}, nil, time.Hour)
+		require.NoError(t, err)
+
+		part, err := core.PrepareImagePart(ctx, "https://example.com/line-art.png")
+		require.NoError(t, err)
+		require.NotNil(t, part.InlineData)
+		assert.Equal(t, "image/png", part.InlineData.MIMEType)
+	})
+
+	t.Run("エンコードできない出力フォーマットは初期化時にエラー", func(t *testing.T) {
+		_, err := NewGeminiImageCore(&mockAIClient{}, &mockReader{}, &mockHTTPClient{data: pngData}, nil, time.Hour,
+			WithCompressionPolicy(imgutil.CompressionPolicy{Enabled: true, Format: imgutil.FormatWebP}))
+		assert.ErrorIs(t, err, imgutil.ErrUnsupportedFormat)
+	})
+
+	t.Run("圧縮に失敗した場合は元データを送信せずにエラー", func(t *testing.T) {
+		ai := &mockAIClient{}
+		core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{data: []byte("not an image")}, nil, time.Hour)
+		require.NoError(t, err)
+
+		_, err = core.UploadFile(ctx, "https://example.com/broken.png")
+		assert.ErrorIs(t, err, ErrInvalidImage)
+		assert.ErrorIs(t, err, imgutil.ErrUnsupportedFormat)
+		assert.False(t, ai.uploadCalled)
+	})
+}
+
+func TestGeminiImageCore_PrepareMaskPart(t *testing.T) {
+	ctx := context.Background()
+
+	mask := image.NewGray(image.Rect(0, 0, 8, 8))
+	for x := 4; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			mask.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, png.Encode(buf, mask))
+	maskData := buf.Bytes()
+
+	core, err := NewGeminiImageCore(&mockAIClient{}, &mockReader{}, &mockHTTPClient{data: maskData}, nil, time.Hour)
+	require.NoError(t, err)
+
+	t.Run("デフォルトポリシーでも JPEG にせず境界を保つ", func(t *testing.T) {
+		inline, err := core.PrepareInlinePart(ctx, maskData, "image/png")
+		require.NoError(t, err)
+		require.Equal(t, "image/jpeg", inline.InlineData.MIMEType, "regular images are compressed to JPEG")
+
+		part, err := core.PrepareMaskPart(ctx, domain.ImageURI{Data: maskData, MimeType: "image/png"})
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", part.InlineData.MIMEType)
+
+		decoded, err := png.Decode(bytes.NewReader(part.InlineData.Data))
+		require.NoError(t, err)
+		for x := 0; x < 8; x++ {
+			want := uint32(0)
+			if x >= 4 {
+				want = 0xffff
+			}
+			r, _, _, _ := decoded.At(x, 0).RGBA()
+			assert.Equal(t, want, r, "pixel (%d,0) should be preserved", x)
+		}
+	})
+
+	t.Run("URL からも取得できる", func(t *testing.T) {
+		part, err := core.PrepareMaskPart(ctx, domain.ImageURI{ReferenceURL: "https://example.com/mask.png"})
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", part.InlineData.MIMEType)
+	})
+
+	t.Run("宣言された MIME タイプと一致しない場合はエラー", func(t *testing.T) {
+		_, err := core.PrepareMaskPart(ctx, domain.ImageURI{Data: maskData, MimeType: "image/jpeg"})
+		assert.ErrorIs(t, err, ErrInvalidImage)
+	})
+}
+
+func TestGeminiImageCore_StripsMetadata(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil))
+	plain := buf.Bytes()
+
+	// SOI の直後に位置情報を含む APP1 (EXIF) を差し込む
+	payload := []byte("Exif\x00\x00GPS:35.6812N,139.7671E")
+	segment := append([]byte{0xFF, 0xE1, 0x00, byte(len(payload) + 2)}, payload...)
+	photo := append(append(append([]byte{}, plain[:2]...), segment...), plain[2:]...)
+
+	ai := &mockAIClient{}
+	core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{data: photo}, nil, time.Hour,
+		WithCompressionPolicy(imgutil.CompressionPolicy{Enabled: false}))
+	require.NoError(t, err)
+
+	_, err = core.UploadFile(context.Background(), "https://example.com/photo.jpg")
+	require.NoError(t, err)
+	assert.NotContains(t, string(ai.lastUploadData), "GPS:", "metadata should be stripped even when compression is disabled")
+	assert.Equal(t, plain, ai.lastUploadData)
+}
+
+func TestGeminiImageCore_MetadataFailClosed(t *testing.T) {
+	// JPEG の SOI の後にセグメント長が壊れた APP1 (EXIF) が続くデータ
+	broken := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF, 'E', 'x', 'i', 'f', 0, 0, 'G', 'P', 'S'}
+
+	ai := &mockAIClient{}
+	core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{data: broken}, nil, time.Hour,
+		WithCompressionPolicy(imgutil.CompressionPolicy{Enabled: false}))
+	require.NoError(t, err)
+
+	_, err = core.UploadFile(context.Background(), "https://example.com/photo.jpg")
+	assert.ErrorIs(t, err, ErrInvalidImage)
+	assert.False(t, ai.uploadCalled, "data with unstripped metadata must not be uploaded")
+}
@@ -0,0 +1,139 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/gemini-image-kit/pkg/imgutil"
+	"google.golang.org/genai"
+)
+
+// EditImage は既存の画像をマスクや指示に従って部分的に編集します。
+// パネル全体を再生成せずに、吹き出しや手の描写などを修正する用途を想定しています。
+func (g *GeminiGenerator) EditImage(ctx context.Context, req domain.ImageEditRequest) (*domain.ImageResponse, error) {
+	if strings.TrimSpace(req.Instruction) == "" {
+		return nil, fmt.Errorf("instruction cannot be empty")
+	}
+	if req.Image.IsEmpty() {
+		return nil, fmt.Errorf("base image is required")
+	}
+
+	mode := req.Mode
+	switch mode {
+	case "":
+		mode = domain.EditModeInpaint
+	case domain.EditModeInpaint, domain.EditModeOutpaint, domain.EditModeBackgroundReplace:
+	default:
+		return nil, fmt.Errorf("unknown edit mode %q", mode)
+	}
+	if mode == domain.EditModeOutpaint && toInsets(req.Padding).IsZero() {
+		return nil, fmt.Errorf("padding is required for outpaint")
+	}
+
+	// 1. 編集元の画像 (アウトペイントの場合は余白を追加)
+	base, err := g.prepareEditPart(ctx, req.Image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare base image: %w", err)
+	}
+	paddedLocally := false
+	if mode == domain.EditModeOutpaint && base.InlineData != nil {
+		padded, err := imgutil.Pad(base.InlineData.Data, toInsets(req.Padding), color.White)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pad base image: %w", err)
+		}
+		base = &genai.Part{InlineData: &genai.Blob{MIMEType: imgutil.FormatPNG.MimeType(), Data: padded}}
+		paddedLocally = true
+	}
+	parts := []*genai.Part{base}
+	used := []domain.ImageURI{req.Image}
+
+	// 2. マスク (可逆な PNG で準備し、透過 PNG は二値マスクに変換)
+	if req.Mask != nil && !req.Mask.IsEmpty() {
+		mask, err := g.prepareMaskPart(ctx, *req.Mask)
+		if err != nil {
+			return nil, fmt.Errorf("failed to prepare mask image: %w", err)
+		}
+		if mask.InlineData != nil {
+			if converted, ok, err := imgutil.AlphaToMask(mask.InlineData.Data); err == nil && ok {
+				mask = &genai.Part{InlineData: &genai.Blob{MIMEType: imgutil.FormatPNG.MimeType(), Data: converted}}
+			}
+		}
+		parts = append(parts, mask)
+		used = append(used, *req.Mask)
+	}
+
+	// 3. 編集モードに応じた指示文
+	hasMask := len(parts) > 1
+	instruction := buildEditInstruction(mode, req.Instruction, req.Padding, hasMask, paddedLocally)
+	parts = append(parts, &genai.Part{Text: g.finalPrompt(instruction, req.NegativePrompt)})
+
+	opts := g.toOptions(req.AspectRatio, req.ImageSize, req.SystemPrompt, req.Seed, req.CandidateCount)
+	opts = g.applyGenerationConfig(opts, req.GenerationConfig)
+	resp, err := g.execute(ctx, g.panelModels(), parts, opts)
+	if err != nil {
+		return nil, err
+	}
+	resp.UsedReferences = used
+	return resp, nil
+}
+
+// prepareEditPart は編集用の画像を1枚準備します。編集元が欠けている場合は常にエラーとします。
+func (g *GeminiGenerator) prepareEditPart(ctx context.Context, uri domain.ImageURI) (*genai.Part, error) {
+	parts, _, err := g.collectImageParts(ctx, []domain.ImageURI{uri}, domain.FailOnMissingReference)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no usable image source")
+	}
+	return parts[0], nil
+}
+
+// prepareMaskPart は編集用のマスクを1枚準備します。
+// core が MaskPreparer を実装している場合は、境界がにじまないよう JPEG に圧縮せずに準備します。
+func (g *GeminiGenerator) prepareMaskPart(ctx context.Context, uri domain.ImageURI) (*genai.Part, error) {
+	preparer, ok := g.core.(MaskPreparer)
+	if !ok || uri.FileAPIURI != "" {
+		return g.prepareEditPart(ctx, uri)
+	}
+	return preparer.PrepareMaskPart(ctx, uri)
+}
+
+// buildEditInstruction は編集モードに応じてモデルへの指示文を組み立てます。
+func buildEditInstruction(mode domain.EditMode, instruction string, padding domain.Padding, hasMask, paddedLocally bool) string {
+	var sb strings.Builder
+
+	switch mode {
+	case domain.EditModeOutpaint:
+		if paddedLocally {
+			sb.WriteString("The first image has been extended with a plain white border. ")
+			sb.WriteString("Fill the white border so that it seamlessly continues the original scene, and keep the original area unchanged.")
+		} else {
+			fmt.Fprintf(&sb, "Extend the canvas of the first image by %dpx on top, %dpx on the right, %dpx on the bottom and %dpx on the left. ",
+				padding.Top, padding.Right, padding.Bottom, padding.Left)
+			sb.WriteString("Fill the new area so that it seamlessly continues the original scene, and keep the original area unchanged.")
+		}
+	case domain.EditModeBackgroundReplace:
+		sb.WriteString("Replace only the background of the first image. ")
+		sb.WriteString("Keep the characters, their poses, outlines and the foreground exactly as they are.")
+	default:
+		if hasMask {
+			sb.WriteString("Edit only the region of the first image that is white in the second image (the mask). ")
+			sb.WriteString("Keep every area that is black in the mask exactly as it is.")
+		} else {
+			sb.WriteString("Edit the first image as instructed, changing only what is necessary and keeping the rest of the image as it is.")
+		}
+	}
+
+	sb.WriteString("\n\nInstruction: ")
+	sb.WriteString(strings.TrimSpace(instruction))
+	return sb.String()
+}
+
+// toInsets はドメインの Padding を imgutil の Insets に変換します。
+func toInsets(p domain.Padding) imgutil.Insets {
+	return imgutil.Insets{Top: p.Top, Right: p.Right, Bottom: p.Bottom, Left: p.Left}
+}
@@ -0,0 +1,160 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func encodeTestPNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	require.NoError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+// maskExecutor は MaskPreparer を実装した mockExecutor です。
+type maskExecutor struct {
+	*mockExecutor
+	maskCalls int
+}
+
+func (m *maskExecutor) PrepareMaskPart(ctx context.Context, uri domain.ImageURI) (*genai.Part, error) {
+	m.maskCalls++
+	return &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: uri.Data}}, nil
+}
+
+func TestGeminiGenerator_EditImage(t *testing.T) {
+	ctx := context.Background()
+	base := encodeTestPNG(t, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+
+	newGenerator := func() (*GeminiGenerator, *mockExecutor) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		require.NoError(t, err)
+		return g, exec
+	}
+
+	t.Run("指示が空の場合はエラー", func(t *testing.T) {
+		g, _ := newGenerator()
+		_, err := g.EditImage(ctx, domain.ImageEditRequest{Image: domain.ImageURI{Data: base}})
+		assert.Error(t, err)
+	})
+
+	t.Run("マスク付きのインペイントでは透過マスクを二値化して送信する", func(t *testing.T) {
+		g, exec := newGenerator()
+		alphaMask := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+		alphaMask.Set(0, 0, color.NRGBA{0, 0, 0, 255})
+
+		resp, err := g.EditImage(ctx, domain.ImageEditRequest{
+			Image:       domain.ImageURI{Data: base},
+			Mask:        &domain.ImageURI{Data: encodeTestPNG(t, alphaMask)},
+			Instruction: "fix the speech bubble",
+		})
+		require.NoError(t, err)
+		require.Len(t, exec.lastParts, 3)
+
+		_, format, err := image.Decode(bytes.NewReader(exec.lastParts[1].InlineData.Data))
+		require.NoError(t, err)
+		assert.Equal(t, "png", format)
+		gray, _, _ := image.Decode(bytes.NewReader(exec.lastParts[1].InlineData.Data))
+		_, ok := gray.(*image.Gray)
+		assert.True(t, ok, "mask should be converted to grayscale")
+
+		assert.Contains(t, exec.lastParts[2].Text, "mask")
+		assert.Contains(t, exec.lastParts[2].Text, "fix the speech bubble")
+		assert.Len(t, resp.UsedReferences, 2)
+	})
+
+	t.Run("アウトペイントでは余白を追加した画像を送信する", func(t *testing.T) {
+		g, exec := newGenerator()
+		_, err := g.EditImage(ctx, domain.ImageEditRequest{
+			Image:       domain.ImageURI{Data: base},
+			Instruction: "extend the sky",
+			Mode:        domain.EditModeOutpaint,
+			Padding:     domain.Padding{Top: 4, Bottom: 4},
+		})
+		require.NoError(t, err)
+
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(exec.lastParts[0].InlineData.Data))
+		require.NoError(t, err)
+		assert.Equal(t, 8, cfg.Width)
+		assert.Equal(t, 16, cfg.Height)
+		assert.Contains(t, exec.lastParts[1].Text, "white border")
+	})
+
+	t.Run("リクエストのセーフティしきい値を送信する", func(t *testing.T) {
+		g, exec := newGenerator()
+		_, err := g.EditImage(ctx, domain.ImageEditRequest{
+			Image:       domain.ImageURI{Data: base},
+			Instruction: "add a punch effect",
+			GenerationConfig: &domain.GenerationConfig{SafetySettings: []domain.SafetySetting{
+				{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_ONLY_HIGH"},
+			}},
+		})
+		require.NoError(t, err)
+		require.Len(t, exec.lastOpts.SafetySettings, 1)
+		assert.Equal(t, genai.HarmBlockThresholdBlockOnlyHigh, exec.lastOpts.SafetySettings[0].Threshold)
+	})
+
+	t.Run("マスクは MaskPreparer で可逆に準備する", func(t *testing.T) {
+		exec := &maskExecutor{mockExecutor: &mockExecutor{}}
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		require.NoError(t, err)
+
+		_, err = g.EditImage(ctx, domain.ImageEditRequest{
+			Image:       domain.ImageURI{Data: base},
+			Mask:        &domain.ImageURI{Data: base},
+			Instruction: "fix the hand",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, exec.maskCalls)
+		require.Len(t, exec.lastParts, 3)
+		assert.Equal(t, "image/png", exec.lastParts[1].InlineData.MIMEType)
+	})
+
+	t.Run("未定義の編集モードはエラー", func(t *testing.T) {
+		g, exec := newGenerator()
+		_, err := g.EditImage(ctx, domain.ImageEditRequest{
+			Image:       domain.ImageURI{Data: base},
+			Instruction: "fix",
+			Mode:        "colorize",
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown edit mode")
+		assert.Nil(t, exec.lastParts, "request should not be sent")
+	})
+
+	t.Run("アウトペイントで余白が未指定の場合はエラー", func(t *testing.T) {
+		g, _ := newGenerator()
+		_, err := g.EditImage(ctx, domain.ImageEditRequest{
+			Image:       domain.ImageURI{Data: base},
+			Instruction: "extend",
+			Mode:        domain.EditModeOutpaint,
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestBuildEditInstruction(t *testing.T) {
+	t.Run("File API 参照のアウトペイントは拡張量を文章で伝える", func(t *testing.T) {
+		got := buildEditInstruction(domain.EditModeOutpaint, "more sky", domain.Padding{Top: 128}, false, false)
+		assert.Contains(t, got, "128px on top")
+		assert.True(t, strings.HasSuffix(got, "Instruction: more sky"))
+	})
+
+	t.Run("背景差し替えは前景の維持を指示する", func(t *testing.T) {
+		got := buildEditInstruction(domain.EditModeBackgroundReplace, "a night city", domain.Padding{}, false, false)
+		assert.Contains(t, got, "background")
+		assert.Contains(t, got, "a night city")
+	})
+}
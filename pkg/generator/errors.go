@@ -0,0 +1,143 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"google.golang.org/genai"
+)
+
+// 呼び出し側がリトライ・プロンプト修正・ジョブ失敗を判断するためのセンチネルエラー。
+// errors.Is で判定できます。
+var (
+	ErrSafetyBlocked     = errors.New("generation blocked by safety filter")
+	ErrRecitation        = errors.New("generation stopped due to recitation")
+	ErrEmptyResponse     = errors.New("invalid or empty response from Gemini")
+	ErrNoImageInResponse = errors.New("no image data found in response parts")
+	ErrGenerationStopped = errors.New("generation stopped before completion")
+	ErrQuotaExceeded     = errors.New("quota exceeded")
+	ErrAssetFetch        = errors.New("failed to fetch image asset")
+	ErrInvalidImage      = errors.New("data is not a supported image")
+	ErrMissingDependency = errors.New("required dependency is missing")
+	ErrBatchJobFailed    = errors.New("batch job did not succeed")
+	ErrUnsupportedOption = errors.New("option is not supported by the Gemini client")
+)
+
+// GenerationError は生成結果の検証で失敗した際の詳細情報を保持します。
+// Kind にはセンチネルエラーが入り、errors.Is / errors.As の両方で判定できます。
+type GenerationError struct {
+	Kind           error
+	FinishReason   genai.FinishReason
+	FinishMessage  string
+	SafetyRatings  []*genai.SafetyRating
+	PromptFeedback *genai.GenerateContentResponsePromptFeedback
+}
+
+func (e *GenerationError) Error() string {
+	if e.PromptFeedback != nil && e.PromptFeedback.BlockReason != "" {
+		return fmt.Sprintf("%v (BlockReason: %s)", e.Kind, e.PromptFeedback.BlockReason)
+	}
+	if e.FinishReason != "" {
+		if triggered := triggeredRatings(toDomainSafetyRatings(e.SafetyRatings)); len(triggered) > 0 {
+			categories := make([]string, 0, len(triggered))
+			for _, r := range triggered {
+				categories = append(categories, r.Category+"="+r.Probability)
+			}
+			return fmt.Sprintf("%v (FinishReason: %s, %s)", e.Kind, e.FinishReason, strings.Join(categories, ", "))
+		}
+		return fmt.Sprintf("%v (FinishReason: %s)", e.Kind, e.FinishReason)
+	}
+	return e.Kind.Error()
+}
+
+func (e *GenerationError) Unwrap() error { return e.Kind }
+
+// newCandidateError は候補の FinishReason から GenerationError を生成します。
+func newCandidateError(candidate *genai.Candidate, feedback *genai.GenerateContentResponsePromptFeedback) *GenerationError {
+	return &GenerationError{
+		Kind:           kindFromFinishReason(candidate.FinishReason),
+		FinishReason:   candidate.FinishReason,
+		FinishMessage:  candidate.FinishMessage,
+		SafetyRatings:  candidate.SafetyRatings,
+		PromptFeedback: feedback,
+	}
+}
+
+// newPromptBlockedError はプロンプト自体がブロックされた場合の GenerationError を生成します。
+func newPromptBlockedError(feedback *genai.GenerateContentResponsePromptFeedback) *GenerationError {
+	kind := ErrSafetyBlocked
+	if feedback.BlockReason == genai.BlockedReasonOther {
+		kind = ErrGenerationStopped
+	}
+	return &GenerationError{
+		Kind:           kind,
+		SafetyRatings:  feedback.SafetyRatings,
+		PromptFeedback: feedback,
+	}
+}
+
+// kindFromFinishReason は FinishReason を対応するセンチネルエラーに分類します。
+func kindFromFinishReason(reason genai.FinishReason) error {
+	switch reason {
+	case genai.FinishReasonSafety,
+		genai.FinishReasonBlocklist,
+		genai.FinishReasonProhibitedContent,
+		genai.FinishReasonSPII,
+		genai.FinishReasonImageSafety,
+		genai.FinishReasonImageProhibitedContent:
+		return ErrSafetyBlocked
+	case genai.FinishReasonRecitation, genai.FinishReasonImageRecitation:
+		return ErrRecitation
+	case genai.FinishReasonNoImage:
+		return ErrNoImageInResponse
+	default:
+		return ErrGenerationStopped
+	}
+}
+
+// classifyClientError は Gemini クライアントから返されたエラーを分類し、
+// 該当するセンチネルエラーでラップします。分類できない場合は元のエラーをそのまま返します。
+func classifyClientError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.Code == http.StatusTooManyRequests || strings.Contains(apiErr.Status, "RESOURCE_EXHAUSTED") {
+			return fmt.Errorf("%w: %w", ErrQuotaExceeded, err)
+		}
+		return err
+	}
+
+	// クライアント側で FinishReason が検証された場合、理由はメッセージにのみ含まれる
+	var respErr *gemini.APIResponseError
+	if errors.As(err, &respErr) {
+		msg := respErr.Error()
+		switch {
+		case strings.Contains(msg, string(genai.FinishReasonRecitation)):
+			return fmt.Errorf("%w: %w", ErrRecitation, err)
+		case containsAny(msg, string(genai.FinishReasonSafety), string(genai.FinishReasonBlocklist),
+			string(genai.FinishReasonProhibitedContent), string(genai.FinishReasonSPII)):
+			return fmt.Errorf("%w: %w", ErrSafetyBlocked, err)
+		case strings.Contains(msg, "空のレスポンス"):
+			return fmt.Errorf("%w: %w", ErrEmptyResponse, err)
+		default:
+			return fmt.Errorf("%w: %w", ErrGenerationStopped, err)
+		}
+	}
+
+	return err
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestGenerationError_IsAndAs(t *testing.T) {
+	ratings := []*genai.SafetyRating{{
+		Category:    genai.HarmCategoryDangerousContent,
+		Probability: genai.HarmProbabilityHigh,
+		Blocked:     true,
+	}}
+	var err error = newCandidateError(&genai.Candidate{
+		FinishReason:  genai.FinishReasonSafety,
+		SafetyRatings: ratings,
+	}, nil)
+
+	assert.ErrorIs(t, err, ErrSafetyBlocked)
+	assert.NotErrorIs(t, err, ErrRecitation)
+
+	var genErr *GenerationError
+	require.ErrorAs(t, err, &genErr)
+	assert.Equal(t, genai.FinishReasonSafety, genErr.FinishReason)
+	assert.Equal(t, ratings, genErr.SafetyRatings)
+	assert.Contains(t, err.Error(), "SAFETY")
+}
+
+func TestKindFromFinishReason(t *testing.T) {
+	tests := []struct {
+		reason genai.FinishReason
+		want   error
+	}{
+		{genai.FinishReasonSafety, ErrSafetyBlocked},
+		{genai.FinishReasonImageSafety, ErrSafetyBlocked},
+		{genai.FinishReasonProhibitedContent, ErrSafetyBlocked},
+		{genai.FinishReasonRecitation, ErrRecitation},
+		{genai.FinishReasonImageRecitation, ErrRecitation},
+		{genai.FinishReasonNoImage, ErrNoImageInResponse},
+		{genai.FinishReasonOther, ErrGenerationStopped},
+		{genai.FinishReasonMaxTokens, ErrGenerationStopped},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.reason), func(t *testing.T) {
+			assert.ErrorIs(t, kindFromFinishReason(tt.reason), tt.want)
+		})
+	}
+}
+
+func TestClassifyClientError(t *testing.T) {
+	t.Run("429 は ErrQuotaExceeded に分類される", func(t *testing.T) {
+		err := classifyClientError(fmt.Errorf("wrapped: %w", genai.APIError{Code: 429, Status: "RESOURCE_EXHAUSTED"}))
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+		var apiErr genai.APIError
+		assert.ErrorAs(t, err, &apiErr, "original error should remain reachable")
+	})
+
+	t.Run("分類できないエラーはそのまま返す", func(t *testing.T) {
+		orig := errors.New("network unreachable")
+		assert.Equal(t, orig, classifyClientError(orig))
+	})
+
+	t.Run("その他の API エラーはそのまま返す", func(t *testing.T) {
+		orig := genai.APIError{Code: 500, Status: "INTERNAL"}
+		err := classifyClientError(orig)
+		assert.Equal(t, error(orig), err)
+	})
+}
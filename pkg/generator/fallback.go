@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"google.golang.org/genai"
+)
+
+// GeneratorOption は GeminiGenerator の任意設定を適用する関数です。
+type GeneratorOption func(*GeminiGenerator)
+
+// WithPanelFallbackModels は model で生成できなかった場合に順に試すモデルを設定します。
+// GenerateMangaPanel と EditImage に適用されます。
+func WithPanelFallbackModels(models ...string) GeneratorOption {
+	return func(g *GeminiGenerator) {
+		g.panelFallbacks = models
+	}
+}
+
+// WithPageFallbackModels は qualityModel で生成できなかった場合に順に試すモデルを設定します。
+// GenerateMangaPage と RefinementSession に適用されます。
+func WithPageFallbackModels(models ...string) GeneratorOption {
+	return func(g *GeminiGenerator) {
+		g.pageFallbacks = models
+	}
+}
+
+// panelModels はパネル生成で試すモデルを優先順に返します。
+func (g *GeminiGenerator) panelModels() []string {
+	return append([]string{g.model}, g.panelFallbacks...)
+}
+
+// pageModels はページ生成で試すモデルを優先順に返します。
+func (g *GeminiGenerator) pageModels() []string {
+	return append([]string{g.qualityModel}, g.pageFallbacks...)
+}
+
+// withModelOverride はリクエストでモデルが指定されている場合、そのモデルを先頭に置いたチェーンを返します。
+// 指定されたモデルが失敗した場合は、操作ごとのチェーンにフォールバックします。
+func withModelOverride(override string, chain []string) []string {
+	if override == "" {
+		return chain
+	}
+	models := []string{override}
+	for _, m := range chain {
+		if m != override {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// execute は models を順に試し、最初に成功したレスポンスを返します。
+// 一時的なエラー・容量不足・モデルが見つからない場合のみ次のモデルに切り替え、
+// それ以外のエラー (安全フィルターなど) はモデルを変えても結果が変わらないため即座に返します。
+// 実際に画像を生成したモデルは ImageResponse.Model に記録されます。
+func (g *GeminiGenerator) execute(ctx context.Context, models []string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error) {
+	var lastErr error
+	for i, model := range models {
+		resp, err := g.core.ExecuteRequest(ctx, model, parts, opts)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+
+		if i == len(models)-1 || !shouldFallback(err) || ctx.Err() != nil {
+			break
+		}
+		slog.WarnContext(ctx, "モデルでの生成に失敗したため、次のモデルで再試行します", "model", model, "next", models[i+1], "error", err)
+	}
+
+	if len(models) > 1 && shouldFallback(lastErr) {
+		return nil, fmt.Errorf("all models failed (%s): %w", strings.Join(models, ", "), lastErr)
+	}
+	return nil, lastErr
+}
+
+// shouldFallback は別のモデルで再試行する価値のあるエラーかどうかを判定します。
+func shouldFallback(err error) bool {
+	if retryClassOf(err) != 0 {
+		return true
+	}
+	var apiErr genai.APIError
+	return errors.As(err, &apiErr) && (apiErr.Code == http.StatusNotFound || apiErr.Status == "NOT_FOUND")
+}
@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// modelExecutor はモデルごとに失敗を設定できる ImageExecutor のモックです。
+type modelExecutor struct {
+	mockExecutor
+	modelErrs map[string]error
+	models    []string
+}
+
+func (m *modelExecutor) ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error) {
+	m.models = append(m.models, model)
+	if err, ok := m.modelErrs[model]; ok {
+		return nil, err
+	}
+	return m.mockExecutor.ExecuteRequest(ctx, model, parts, opts)
+}
+
+func TestGeminiGenerator_Fallback(t *testing.T) {
+	ctx := context.Background()
+
+	newGenerator := func(t *testing.T, errs map[string]error) (*GeminiGenerator, *modelExecutor) {
+		exec := &modelExecutor{modelErrs: errs}
+		g, err := NewGeminiGenerator("fast", "quality", exec,
+			WithPanelFallbackModels("fast-backup"),
+			WithPageFallbackModels("quality-backup", "fast"),
+		)
+		require.NoError(t, err)
+		return g, exec
+	}
+
+	t.Run("容量不足の場合は次のモデルで生成し、使用したモデルを記録する", func(t *testing.T) {
+		g, exec := newGenerator(t, map[string]error{
+			"quality": classifyClientError(genai.APIError{Code: 429, Status: "RESOURCE_EXHAUSTED"}),
+		})
+		resp, err := g.GenerateMangaPage(ctx, domain.ImagePageRequest{Prompt: "page"})
+		require.NoError(t, err)
+		assert.Equal(t, "quality-backup", resp.Model)
+		assert.Equal(t, []string{"quality", "quality-backup"}, exec.models)
+	})
+
+	t.Run("モデルが見つからない場合も次のモデルに切り替える", func(t *testing.T) {
+		g, exec := newGenerator(t, map[string]error{
+			"quality":        genai.APIError{Code: 404, Status: "NOT_FOUND"},
+			"quality-backup": genai.APIError{Code: 503},
+		})
+		resp, err := g.GenerateMangaPage(ctx, domain.ImagePageRequest{Prompt: "page"})
+		require.NoError(t, err)
+		assert.Equal(t, "fast", resp.Model)
+		assert.Len(t, exec.models, 3)
+	})
+
+	t.Run("安全フィルターによるブロックでは切り替えない", func(t *testing.T) {
+		g, exec := newGenerator(t, map[string]error{
+			"fast": &GenerationError{Kind: ErrSafetyBlocked, FinishReason: genai.FinishReasonSafety},
+		})
+		_, err := g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "panel"})
+		assert.ErrorIs(t, err, ErrSafetyBlocked)
+		assert.Equal(t, []string{"fast"}, exec.models)
+	})
+
+	t.Run("全てのモデルが失敗した場合は最後のエラーを返す", func(t *testing.T) {
+		g, _ := newGenerator(t, map[string]error{
+			"fast":        genai.APIError{Code: 503},
+			"fast-backup": classifyClientError(genai.APIError{Code: 429}),
+		})
+		_, err := g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "panel"})
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.Contains(t, err.Error(), "fast, fast-backup")
+	})
+
+	t.Run("フォールバック未設定の場合は従来どおり単一モデルを使う", func(t *testing.T) {
+		exec := &modelExecutor{modelErrs: map[string]error{"fast": genai.APIError{Code: 503}}}
+		g, err := NewGeminiGenerator("fast", "quality", exec)
+		require.NoError(t, err)
+
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "panel"})
+		assert.Error(t, err)
+		assert.Equal(t, []string{"fast"}, exec.models)
+	})
+}
@@ -3,10 +3,10 @@ package generator
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/shouni/gemini-image-kit/pkg/domain"
-	"github.com/shouni/go-gemini-client/pkg/gemini"
 	"google.golang.org/genai"
 )
 
@@ -14,13 +14,20 @@ const negativePromptSeparator = "\n\n[Negative Prompt]\n"
 
 // GeminiGenerator は高レベルな画像生成ロジックを担当します。
 type GeminiGenerator struct {
-	model        string
-	qualityModel string
-	core         ImageExecutor
+	model          string
+	qualityModel   string
+	core           ImageExecutor
+	panelFallbacks []string
+	pageFallbacks  []string
+	safetySettings []domain.SafetySetting
+	characters     *CharacterRegistry
+	styles         *StyleLibrary
+	templates      *PromptTemplates
+	negativeFormat NegativePromptFormatter
 }
 
 // NewGeminiGenerator は新しい GeminiGenerator を作成します。
-func NewGeminiGenerator(model, qualityModel string, core ImageExecutor) (*GeminiGenerator, error) {
+func NewGeminiGenerator(model, qualityModel string, core ImageExecutor, opts ...GeneratorOption) (*GeminiGenerator, error) {
 	if model == "" {
 		return nil, fmt.Errorf("model is required")
 	}
@@ -31,64 +38,101 @@ func NewGeminiGenerator(model, qualityModel string, core ImageExecutor) (*Gemini
 		return nil, fmt.Errorf("core (ImageExecutor) is required")
 	}
 
-	return &GeminiGenerator{
+	g := &GeminiGenerator{
 		model:        model,
 		qualityModel: qualityModel,
 		core:         core,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, nil
 }
 
 // GenerateMangaPanel は単一のパネル画像を生成します。
 func (g *GeminiGenerator) GenerateMangaPanel(ctx context.Context, req domain.ImageGenerationRequest) (*domain.ImageResponse, error) {
-	return g.generate(
-		ctx,
-		g.model,
-		req.Prompt,
-		req.NegativePrompt,
-		[]domain.ImageURI{req.Image},
-		req.AspectRatio,
-		req.ImageSize,
-		req.SystemPrompt,
-		req.Seed,
-	)
+	return g.generate(ctx, g.panelModels(), req.ToPageRequest())
 }
 
 // GenerateMangaPage は複数アセットを参照してページ画像を生成します。
 func (g *GeminiGenerator) GenerateMangaPage(ctx context.Context, req domain.ImagePageRequest) (*domain.ImageResponse, error) {
-	return g.generate(
-		ctx,
-		g.qualityModel,
-		req.Prompt,
-		req.NegativePrompt,
-		req.Images,
-		req.AspectRatio,
-		req.ImageSize,
-		req.SystemPrompt,
-		req.Seed,
-	)
+	return g.generate(ctx, g.pageModels(), req)
 }
 
 // generate は画像生成のコアロジックです。
-func (g *GeminiGenerator) generate(ctx context.Context, model, prompt, negative string, uris []domain.ImageURI, ar, size, sp string, seed *int64) (*domain.ImageResponse, error) {
-	finalPrompt := buildFinalPrompt(prompt, negative)
-	if finalPrompt == "" {
-		return nil, fmt.Errorf("prompt cannot be empty")
+// パネル生成も参照画像が1枚のページ生成として扱います。
+func (g *GeminiGenerator) generate(ctx context.Context, models []string, req domain.ImagePageRequest) (*domain.ImageResponse, error) {
+	parts, refs, opts, err := g.prepareRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.execute(ctx, withModelOverride(req.Model, models), parts, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.UsedReferences = refs.used
+	resp.SkippedReferences = refs.skipped
+	return resp, nil
+}
+
+// prepareRequest はリクエストを送信用のパーツとオプションに変換します。
+func (g *GeminiGenerator) prepareRequest(ctx context.Context, req domain.ImagePageRequest) ([]*genai.Part, referenceReport, GenerateOptions, error) {
+	// 0. テンプレートを描画し、スタイルプリセットのマージとキャラクター参照の展開を行う
+	req, err := g.renderPrompt(req)
+	if err != nil {
+		return nil, referenceReport{}, GenerateOptions{}, err
+	}
+	if g.finalPrompt(req.Prompt, req.NegativePrompt) == "" {
+		return nil, referenceReport{}, GenerateOptions{}, fmt.Errorf("prompt cannot be empty")
 	}
+	req, err = g.applyStyle(req)
+	if err != nil {
+		return nil, referenceReport{}, GenerateOptions{}, err
+	}
+	req, err = g.resolveCharacters(ctx, req)
+	if err != nil {
+		return nil, referenceReport{}, GenerateOptions{}, err
+	}
+	finalPrompt := g.finalPrompt(req.Prompt, req.NegativePrompt)
 
 	// 1. 画像アセット（素材）を収集
-	parts := g.collectImageParts(ctx, uris)
+	parts, refs, err := g.collectImageParts(ctx, req.Images, req.ReferencePolicy)
+	if err != nil {
+		return nil, referenceReport{}, GenerateOptions{}, err
+	}
 
-	// 2. 最後にテキストプロンプトを追加
+	// 2. インライン画像の合計サイズをリクエストの上限内に収める
+	budget := req.PayloadBudget
+	if budget == 0 {
+		budget = DefaultRequestPayloadBudget
+	}
+	parts, refs, err = dropUnfitReferences(ctx, parts, refs, fitPartsToBudget(parts, budget), req.ReferencePolicy)
+	if err != nil {
+		return nil, referenceReport{}, GenerateOptions{}, err
+	}
+
+	// 3. 最後にテキストプロンプトを追加
 	parts = append(parts, &genai.Part{Text: finalPrompt})
 
-	// 3. ImageSize を含めたオプション構築
-	opts := g.toOptions(ar, size, sp, seed)
-	return g.core.ExecuteRequest(ctx, model, parts, opts)
+	// 4. ImageSize を含めたオプション構築
+	opts := g.toOptions(req.AspectRatio, req.ImageSize, req.SystemPrompt, req.Seed, req.CandidateCount)
+	opts = g.applyGenerationConfig(opts, req.GenerationConfig)
+	return parts, refs, opts, nil
+}
+
+// referenceReport は参照画像の使用状況を集計します。
+type referenceReport struct {
+	used    []domain.ImageURI
+	skipped []domain.SkippedReference
 }
 
 // collectImageParts は ImageURI 構造体からパーツを生成します。
-func (g *GeminiGenerator) collectImageParts(ctx context.Context, uris []domain.ImageURI) []*genai.Part {
+// 参照画像が準備できなかった場合の扱いは policy に従います。
+func (g *GeminiGenerator) collectImageParts(ctx context.Context, uris []domain.ImageURI, policy domain.ReferencePolicy) ([]*genai.Part, referenceReport, error) {
 	parts := make([]*genai.Part, 0, len(uris))
+	var report referenceReport
 
 	for _, uri := range uris {
 		// Gemini File API URI がある場合は最優先で使用
@@ -96,27 +140,93 @@ func (g *GeminiGenerator) collectImageParts(ctx context.Context, uris []domain.I
 			parts = append(parts, &genai.Part{
 				FileData: &genai.FileData{FileURI: uri.FileAPIURI},
 			})
+			report.used = append(report.used, uri)
 			continue
 		}
 
-		// なければ ReferenceURL からフォールバック
-		if uri.ReferenceURL != "" {
-			if res := g.core.PrepareImagePart(ctx, uri.ReferenceURL); res != nil {
-				parts = append(parts, res)
+		// 次にメモリ上のデータ、なければ ReferenceURL からフォールバック
+		var part *genai.Part
+		var err error
+		switch {
+		case len(uri.Data) > 0:
+			part, err = g.core.PrepareInlinePart(ctx, uri.Data, uri.MimeType)
+		case uri.ReferenceURL != "":
+			part, err = g.core.PrepareImagePart(ctx, uri.ReferenceURL)
+		default:
+			continue
+		}
+		if err != nil {
+			if err := skipReference(ctx, policy, uri, err, &report); err != nil {
+				return nil, report, err
 			}
+			continue
 		}
+
+		parts = append(parts, part)
+		report.used = append(report.used, uri)
+	}
+	return parts, report, nil
+}
+
+// skipReference は準備できなかった参照画像を policy に従って扱います。
+// FailOnMissingReference の場合はエラーを返し、それ以外は除外した参照画像として report に記録します。
+func skipReference(ctx context.Context, policy domain.ReferencePolicy, uri domain.ImageURI, cause error, report *referenceReport) error {
+	switch policy {
+	case domain.FailOnMissingReference:
+		return fmt.Errorf("failed to prepare reference image %s: %w", describeImage(uri), cause)
+	case domain.WarnOnly:
+		slog.WarnContext(ctx, "参照画像を準備できなかったため、参照なしで生成を続行します",
+			"url", describeImage(uri),
+			"error", cause,
+		)
+	}
+	report.skipped = append(report.skipped, domain.SkippedReference{Image: uri, Reason: cause.Error()})
+	return nil
+}
+
+// describeImage はログやエラーメッセージ用に参照画像を識別する文字列を返します。
+func describeImage(uri domain.ImageURI) string {
+	if len(uri.Data) > 0 {
+		return fmt.Sprintf("<inline %d bytes>", len(uri.Data))
 	}
-	return parts
+	return uri.ReferenceURL
 }
 
 // toOptions は Gemini へのリクエストオプションを構築します。
-func (g *GeminiGenerator) toOptions(ar, size, sp string, seed *int64) gemini.GenerateOptions {
-	return gemini.GenerateOptions{
+func (g *GeminiGenerator) toOptions(ar, size, sp string, seed *int64, candidates int) GenerateOptions {
+	opts := GenerateOptions{
 		AspectRatio:  ar,
 		ImageSize:    size,
 		SystemPrompt: sp,
 		Seed:         seed,
 	}
+	if candidates > 0 {
+		opts.CandidateCount = genai.Ptr(int32(candidates))
+	}
+	return opts
+}
+
+// applyGenerationConfig はリクエスト単位の生成パラメータとデフォルトのセーフティ設定をオプションに反映します。
+// セーフティ設定以外の項目は、ContentModel を実装したクライアント (GenAIClient) または BatchJobSubmitter でのみ送信できます。
+func (g *GeminiGenerator) applyGenerationConfig(opts GenerateOptions, cfg *domain.GenerationConfig) GenerateOptions {
+	var safety []domain.SafetySetting
+	if cfg != nil {
+		if cfg.Temperature != nil {
+			opts.Temperature = cfg.Temperature
+		}
+		if cfg.TopP != nil {
+			opts.TopP = cfg.TopP
+		}
+		if cfg.TopK != nil {
+			opts.TopK = cfg.TopK
+		}
+		if cfg.ResponseMIMEType != "" {
+			opts.ResponseMIMEType = cfg.ResponseMIMEType
+		}
+		safety = cfg.SafetySettings
+	}
+	opts.SafetySettings = toGenaiSafetySettings(mergeSafetySettings(g.safetySettings, safety))
+	return opts
 }
 
 // buildFinalPrompt はプロンプトと否定プロンプトを結合します。
@@ -1,9 +1,12 @@
 package generator
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"google.golang.org/genai"
 )
 
 // buildFinalPrompt の単体テスト
@@ -88,3 +91,153 @@ func TestGeminiGenerator_GenerateMangaPage_Structure(t *testing.T) {
 		}
 	})
 }
+
+func TestGeminiGenerator_ToOptions(t *testing.T) {
+	g := &GeminiGenerator{}
+
+	t.Run("CandidateCount が指定された場合はオプションに反映される", func(t *testing.T) {
+		opts := g.toOptions("16:9", "2K", "system", nil, 3)
+		if opts.CandidateCount == nil || *opts.CandidateCount != 3 {
+			t.Errorf("CandidateCount should be 3, got %v", opts.CandidateCount)
+		}
+	})
+
+	t.Run("CandidateCount が 0 の場合は未指定となる", func(t *testing.T) {
+		opts := g.toOptions("", "", "", nil, 0)
+		if opts.CandidateCount != nil {
+			t.Errorf("CandidateCount should be nil, got %v", *opts.CandidateCount)
+		}
+	})
+}
+
+func TestGeminiGenerator_ReferencePolicy(t *testing.T) {
+	ctx := context.Background()
+	missing := domain.ImageURI{ReferenceURL: "gs://bucket/missing.png"}
+	present := domain.ImageURI{ReferenceURL: "gs://bucket/present.png"}
+
+	newGenerator := func() (*GeminiGenerator, *mockExecutor) {
+		exec := &mockExecutor{errs: map[string]error{missing.ReferenceURL: ErrAssetFetch}}
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		if err != nil {
+			t.Fatalf("failed to create generator: %v", err)
+		}
+		return g, exec
+	}
+
+	t.Run("WarnOnly では欠落した参照を除外して生成し、結果に記録する", func(t *testing.T) {
+		g, exec := newGenerator()
+		resp, err := g.GenerateMangaPage(ctx, domain.ImagePageRequest{
+			Prompt: "page",
+			Images: []domain.ImageURI{missing, present},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// 参照画像1枚 + テキストプロンプト
+		if len(exec.lastParts) != 2 {
+			t.Errorf("expected 2 parts, got %d", len(exec.lastParts))
+		}
+		if len(resp.UsedReferences) != 1 || resp.UsedReferences[0].ReferenceURL != present.ReferenceURL {
+			t.Errorf("UsedReferences mismatch: %+v", resp.UsedReferences)
+		}
+		if len(resp.SkippedReferences) != 1 || resp.SkippedReferences[0].Image.ReferenceURL != missing.ReferenceURL {
+			t.Errorf("SkippedReferences mismatch: %+v", resp.SkippedReferences)
+		}
+	})
+
+	t.Run("FailOnMissingReference ではエラーを返し、生成を実行しない", func(t *testing.T) {
+		g, exec := newGenerator()
+		_, err := g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{
+			Prompt:          "panel",
+			Image:           missing,
+			ReferencePolicy: domain.FailOnMissingReference,
+		})
+		if !errors.Is(err, ErrAssetFetch) {
+			t.Errorf("expected ErrAssetFetch, got %v", err)
+		}
+		if exec.lastParts != nil {
+			t.Error("ExecuteRequest should not be called")
+		}
+	})
+}
+
+func TestGeminiGenerator_InlineData(t *testing.T) {
+	exec := &mockExecutor{}
+	g, err := NewGeminiGenerator("model", "quality-model", exec)
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+
+	previous := []byte("previous-panel")
+	_, err = g.GenerateMangaPage(context.Background(), domain.ImagePageRequest{
+		Prompt: "continue the scene",
+		Images: []domain.ImageURI{
+			{Data: previous, MimeType: "image/png", ReferenceURL: "gs://bucket/ignored.png"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exec.inlineCalls != 1 {
+		t.Errorf("PrepareInlinePart should be called once, got %d", exec.inlineCalls)
+	}
+	if string(exec.lastParts[0].InlineData.Data) != string(previous) {
+		t.Errorf("inline data should take precedence over ReferenceURL")
+	}
+}
+
+func TestGeminiGenerator_RequestOverrides(t *testing.T) {
+	temperature := float32(0.4)
+	topP := float32(0.9)
+
+	t.Run("リクエストで指定したモデルと生成パラメータが使われる", func(t *testing.T) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		if err != nil {
+			t.Fatalf("failed to create generator: %v", err)
+		}
+
+		resp, err := g.GenerateMangaPanel(context.Background(), domain.ImageGenerationRequest{
+			Prompt: "chapter 3",
+			Model:  "model-b",
+			GenerationConfig: &domain.GenerationConfig{
+				Temperature: &temperature,
+				TopP:        &topP,
+				SafetySettings: []domain.SafetySetting{
+					{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_ONLY_HIGH"},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if exec.lastModel != "model-b" || resp.Model != "model-b" {
+			t.Errorf("model override was not applied: %s", exec.lastModel)
+		}
+		if exec.lastOpts.Temperature == nil || *exec.lastOpts.Temperature != temperature {
+			t.Errorf("temperature was not passed through: %v", exec.lastOpts.Temperature)
+		}
+		if exec.lastOpts.TopP == nil || *exec.lastOpts.TopP != topP {
+			t.Errorf("topP was not passed through: %v", exec.lastOpts.TopP)
+		}
+		if len(exec.lastOpts.SafetySettings) != 1 || exec.lastOpts.SafetySettings[0].Threshold != genai.HarmBlockThresholdBlockOnlyHigh {
+			t.Errorf("safety settings were not passed through: %+v", exec.lastOpts.SafetySettings)
+		}
+	})
+
+	t.Run("指定したモデルが失敗した場合は操作ごとのチェーンにフォールバックする", func(t *testing.T) {
+		exec := &modelExecutor{modelErrs: map[string]error{"model-b": genai.APIError{Code: 404}}}
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		if err != nil {
+			t.Fatalf("failed to create generator: %v", err)
+		}
+
+		resp, err := g.GenerateMangaPage(context.Background(), domain.ImagePageRequest{Prompt: "page", Model: "model-b"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Model != "quality-model" {
+			t.Errorf("expected fallback to quality-model, got %s", resp.Model)
+		}
+	})
+}
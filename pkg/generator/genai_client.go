@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"iter"
+	"math"
+
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"google.golang.org/genai"
+)
+
+// GenAIClient は google.golang.org/genai を直接呼び出す Gemini クライアントです。
+// gemini.GenerativeModel・ContentModel・StreamingModel を実装し、CandidateCount などの生成パラメータをそのまま API に送信します。
+// レスポンスは候補を検証せずに返すため、ブロックされた場合も ParseToResponse で安全性評価を参照できます。
+// 再試行は行わないため、必要に応じて WithRetryPolicy と組み合わせて使用してください。
+type GenAIClient struct {
+	client *genai.Client
+}
+
+// NewGenAIClient は genai.Client を用いる GenAIClient を作成します。
+func NewGenAIClient(client *genai.Client) (*GenAIClient, error) {
+	if client == nil {
+		return nil, fmt.Errorf("%w: genai client is required", ErrMissingDependency)
+	}
+	return &GenAIClient{client: client}, nil
+}
+
+// GenerateContent はテキストプロンプトからコンテンツを生成します。(gemini.GenerativeModel インターフェース実装)
+func (c *GenAIClient) GenerateContent(ctx context.Context, modelName string, prompt string) (*gemini.Response, error) {
+	if prompt == "" {
+		return nil, gemini.ErrEmptyPrompt
+	}
+	return c.GenerateWithParts(ctx, modelName, []*genai.Part{{Text: prompt}}, gemini.GenerateOptions{})
+}
+
+// GenerateWithParts はマルチモーダルパーツからコンテンツを生成します。(gemini.GenerativeModel インターフェース実装)
+func (c *GenAIClient) GenerateWithParts(ctx context.Context, modelName string, parts []*genai.Part, opts gemini.GenerateOptions) (*gemini.Response, error) {
+	raw, err := c.GenerateContentWithConfig(ctx, modelName, parts, contentConfig(fromClientOptions(opts)))
+	if err != nil {
+		return nil, err
+	}
+	return &gemini.Response{Text: firstText(raw), RawResponse: raw}, nil
+}
+
+// GenerateContentWithConfig は config をそのまま送信し、API のレスポンスを返します。(ContentModel インターフェース実装)
+func (c *GenAIClient) GenerateContentWithConfig(ctx context.Context, modelName string, parts []*genai.Part, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error) {
+	contents := []*genai.Content{{Role: genai.RoleUser, Parts: parts}}
+	return c.client.Models.GenerateContent(ctx, modelName, contents, config)
+}
+
+// GenerateContentStream は config をそのまま送信し、ストリーミングで届いたチャンクを順に返します。(StreamingModel インターフェース実装)
+func (c *GenAIClient) GenerateContentStream(ctx context.Context, modelName string, parts []*genai.Part, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	contents := []*genai.Content{{Role: genai.RoleUser, Parts: parts}}
+	return c.client.Models.GenerateContentStream(ctx, modelName, contents, config)
+}
+
+// UploadFile はデータを File API にアップロードし、利用可能になるまで待機して URI と名前を返します。
+func (c *GenAIClient) UploadFile(ctx context.Context, data []byte, mimeType, displayName string) (string, string, error) {
+	file, err := c.client.Files.Upload(ctx, bytes.NewReader(data), &genai.UploadFileConfig{
+		MIMEType:    mimeType,
+		DisplayName: displayName,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to upload file: %w", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, gemini.PollingTimeout)
+	defer cancel()
+	for file.State == genai.FileStateProcessing {
+		if err := sleepContext(waitCtx, gemini.PollingInterval); err != nil {
+			return "", "", fmt.Errorf("file %s did not become active: %w", file.Name, err)
+		}
+		if file, err = c.client.Files.Get(waitCtx, file.Name, nil); err != nil {
+			return "", "", fmt.Errorf("failed to get file status: %w", err)
+		}
+	}
+	if file.State == genai.FileStateFailed {
+		_ = c.DeleteFile(ctx, file.Name)
+		return "", "", fmt.Errorf("file processing failed on the server: %s", file.Name)
+	}
+	return file.URI, file.Name, nil
+}
+
+// DeleteFile は File API からファイルを削除します。
+func (c *GenAIClient) DeleteFile(ctx context.Context, fileName string) error {
+	if fileName == "" {
+		return nil
+	}
+	if _, err := c.client.Files.Delete(ctx, fileName, nil); err != nil {
+		return fmt.Errorf("failed to delete file %s: %w", fileName, err)
+	}
+	return nil
+}
+
+// contentConfig は GenerateOptions の全項目を SDK の生成設定に変換します。
+func contentConfig(opts GenerateOptions) *genai.GenerateContentConfig {
+	cfg := &genai.GenerateContentConfig{
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		TopK:             opts.TopK,
+		ResponseMIMEType: opts.ResponseMIMEType,
+		Seed:             seedToInt32(opts.Seed),
+		SafetySettings:   opts.SafetySettings,
+	}
+	if opts.CandidateCount != nil {
+		cfg.CandidateCount = *opts.CandidateCount
+	}
+	if opts.SystemPrompt != "" {
+		cfg.SystemInstruction = &genai.Content{Parts: []*genai.Part{{Text: opts.SystemPrompt}}}
+	}
+	if opts.AspectRatio != "" || opts.ImageSize != "" {
+		cfg.ImageConfig = &genai.ImageConfig{AspectRatio: opts.AspectRatio, ImageSize: opts.ImageSize}
+	}
+	return cfg
+}
+
+// seedToInt32 は API が受け付ける int32 の範囲のシードのみを変換します。範囲外の場合は未指定とします。
+func seedToInt32(seed *int64) *int32 {
+	if seed == nil || *seed < math.MinInt32 || *seed > math.MaxInt32 {
+		return nil
+	}
+	return genai.Ptr(int32(*seed))
+}
+
+// firstText は先頭の候補に含まれる最初のテキストを返します。
+func firstText(resp *genai.GenerateContentResponse) string {
+	if resp == nil || len(resp.Candidates) == 0 || resp.Candidates[0] == nil || resp.Candidates[0].Content == nil {
+		return ""
+	}
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part != nil && part.Text != "" && !part.Thought {
+			return part.Text
+		}
+	}
+	return ""
+}
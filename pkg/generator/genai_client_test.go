@@ -0,0 +1,243 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// genaiTestServer は Gemini REST API を模したサーバーで、受信したリクエスト本文を記録します。
+type genaiTestServer struct {
+	mu       sync.Mutex
+	requests []map[string]any
+	paths    []string
+	respond  func(w http.ResponseWriter, r *http.Request)
+}
+
+func (s *genaiTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req map[string]any
+	_ = json.Unmarshal(body, &req)
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	s.paths = append(s.paths, r.URL.Path)
+	s.mu.Unlock()
+	s.respond(w, r)
+}
+
+// lastGenerationConfig は最後に受信したリクエストの generationConfig を返します。
+func (s *genaiTestServer) lastGenerationConfig(t *testing.T) map[string]any {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	require.NotEmpty(t, s.requests)
+	cfg, _ := s.requests[len(s.requests)-1]["generationConfig"].(map[string]any)
+	require.NotNil(t, cfg, "generationConfig must be sent")
+	return cfg
+}
+
+// respondJSON は resp を JSON で返すハンドラーです。
+func respondJSON(resp *genai.GenerateContentResponse) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
+
+// newTestGenAIClient はテストサーバーに接続する実際の genai.Client から GenAIClient を作成します。
+func newTestGenAIClient(t *testing.T, srv *genaiTestServer) *GenAIClient {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:      "test-key",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPClient:  ts.Client(),
+		HTTPOptions: genai.HTTPOptions{BaseURL: ts.URL},
+	})
+	require.NoError(t, err)
+	ai, err := NewGenAIClient(client)
+	require.NoError(t, err)
+	return ai
+}
+
+func imageCandidate(index int32, data string) *genai.Candidate {
+	return &genai.Candidate{
+		Index:        index,
+		FinishReason: genai.FinishReasonStop,
+		Content: &genai.Content{Role: genai.RoleModel, Parts: []*genai.Part{
+			{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte(data)}},
+		}},
+	}
+}
+
+func TestGenAIClient_CandidateCount(t *testing.T) {
+	ctx := context.Background()
+	srv := &genaiTestServer{respond: respondJSON(&genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{imageCandidate(0, "first"), imageCandidate(1, "second")},
+	})}
+	core, err := NewGeminiImageCore(newTestGenAIClient(t, srv), &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+	require.NoError(t, err)
+
+	resp, err := core.ExecuteRequest(ctx, "model", []*genai.Part{{Text: "a cat"}}, GenerateOptions{
+		CandidateCount: genai.Ptr[int32](2),
+		Seed:           genai.Ptr[int64](42),
+		AspectRatio:    "16:9",
+	})
+	require.NoError(t, err)
+
+	cfg := srv.lastGenerationConfig(t)
+	assert.EqualValues(t, 2, cfg["candidateCount"])
+	assert.EqualValues(t, 42, cfg["seed"])
+	assert.Equal(t, map[string]any{"aspectRatio": "16:9"}, cfg["imageConfig"])
+
+	require.Len(t, resp.Images, 2)
+	assert.Equal(t, []byte("first"), resp.Images[0].Data)
+	assert.Equal(t, 1, resp.Images[1].CandidateIndex)
+}
+
+func TestGeminiImageCore_ExecuteRequest_UnsupportedOptions(t *testing.T) {
+	core, err := NewGeminiImageCore(&mockAIClient{}, &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+	require.NoError(t, err)
+
+	for name, opts := range map[string]GenerateOptions{
+		"CandidateCount":   {CandidateCount: genai.Ptr[int32](2)},
+		"Temperature":      {Temperature: genai.Ptr[float32](0.2)},
+		"TopP":             {TopP: genai.Ptr[float32](0.9)},
+		"TopK":             {TopK: genai.Ptr[float32](40)},
+		"ResponseMIMEType": {ResponseMIMEType: "image/png"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := core.ExecuteRequest(context.Background(), "model", nil, opts)
+			assert.ErrorIs(t, err, ErrUnsupportedOption)
+			assert.ErrorContains(t, err, name)
+		})
+	}
+
+	_, err = core.ExecuteRequest(context.Background(), "model", nil, GenerateOptions{CandidateCount: genai.Ptr[int32](1)})
+	assert.NoError(t, err, "a single candidate is what the client sends anyway")
+}
+
+func TestGenAIClient_GenerationConfig(t *testing.T) {
+	srv := &genaiTestServer{respond: respondJSON(&genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{imageCandidate(0, "image")},
+	})}
+	core, err := NewGeminiImageCore(newTestGenAIClient(t, srv), &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+	require.NoError(t, err)
+	gen, err := NewGeminiGenerator("model", "quality", core)
+	require.NoError(t, err)
+
+	_, err = gen.GenerateMangaPanel(context.Background(), domain.ImageGenerationRequest{
+		Prompt: "a cat",
+		GenerationConfig: &domain.GenerationConfig{
+			Temperature:      genai.Ptr[float32](0.3),
+			TopP:             genai.Ptr[float32](0.5),
+			TopK:             genai.Ptr[float32](20),
+			ResponseMIMEType: "image/png",
+		},
+	})
+	require.NoError(t, err)
+
+	cfg := srv.lastGenerationConfig(t)
+	assert.InDelta(t, 0.3, cfg["temperature"], 1e-6)
+	assert.InDelta(t, 0.5, cfg["topP"], 1e-6)
+	assert.EqualValues(t, 20, cfg["topK"])
+	assert.Equal(t, "image/png", cfg["responseMimeType"])
+}
+
+func TestGenAIClient_SafetyReport(t *testing.T) {
+	ctx := context.Background()
+	newCore := func(t *testing.T, resp *genai.GenerateContentResponse) *GeminiImageCore {
+		core, err := NewGeminiImageCore(newTestGenAIClient(t, &genaiTestServer{respond: respondJSON(resp)}),
+			&mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+		require.NoError(t, err)
+		return core
+	}
+
+	t.Run("ブロックされた候補の評価をレポートに含める", func(t *testing.T) {
+		core := newCore(t, &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+			FinishReason: genai.FinishReasonImageSafety,
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryDangerousContent, Probability: genai.HarmProbabilityHigh, Blocked: true},
+				{Category: genai.HarmCategoryHarassment, Probability: genai.HarmProbabilityLow},
+			},
+		}}})
+
+		_, err := core.ExecuteRequest(ctx, "model", []*genai.Part{{Text: "a fight scene"}}, GenerateOptions{})
+		report, ok := SafetyReport(err)
+		require.True(t, ok)
+		assert.Equal(t, "IMAGE_SAFETY", report.FinishReason)
+		assert.Len(t, report.Ratings, 2)
+		assert.Equal(t, []domain.SafetyRating{
+			{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Probability: "HIGH", Blocked: true},
+		}, report.Triggered)
+	})
+
+	t.Run("プロンプトのブロックを PromptFeedback から報告する", func(t *testing.T) {
+		core := newCore(t, &genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{
+			BlockReason: genai.BlockedReasonSafety,
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryHateSpeech, Probability: genai.HarmProbabilityHigh, Blocked: true},
+			},
+		}})
+
+		_, err := core.ExecuteRequest(ctx, "model", []*genai.Part{{Text: "a fight scene"}}, GenerateOptions{})
+		report, ok := SafetyReport(err)
+		require.True(t, ok)
+		assert.Equal(t, "SAFETY", report.BlockReason)
+		require.Len(t, report.Triggered, 1)
+		assert.Equal(t, "HARM_CATEGORY_HATE_SPEECH", report.Triggered[0].Category)
+	})
+}
+
+// respondSSE は chunks を Server-Sent Events として順に返すハンドラーです。
+func respondSSE(chunks ...*genai.GenerateContentResponse) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			data, _ := json.Marshal(c)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func TestGenAIClient_Stream(t *testing.T) {
+	ctx := context.Background()
+	srv := &genaiTestServer{respond: respondSSE(
+		chunk("", &genai.Part{Text: "planning", Thought: true}),
+		chunk("", &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("draft")}, Thought: true}),
+		chunk(genai.FinishReasonStop, &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("final")}}),
+	)}
+	core, err := NewGeminiImageCore(newTestGenAIClient(t, srv), &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+	require.NoError(t, err)
+
+	events, err := collectStream(t, core.ExecuteRequestStream(ctx, "model", []*genai.Part{{Text: "a cat"}}, GenerateOptions{
+		TopK: genai.Ptr[float32](10),
+	}))
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	assert.Equal(t, domain.StreamEventThought, events[0].Kind)
+	assert.Equal(t, domain.StreamEventImage, events[1].Kind)
+	assert.Equal(t, []byte("draft"), events[1].Image.Data)
+	assert.Equal(t, domain.StreamEventImage, events[2].Kind)
+	assert.Equal(t, domain.StreamEventFinal, events[3].Kind)
+	assert.Equal(t, []byte("final"), events[3].Response.Data)
+	assert.Len(t, events[3].Response.Images, 1)
+	assert.EqualValues(t, 10, srv.lastGenerationConfig(t)["topK"])
+	assert.True(t, strings.HasSuffix(srv.paths[0], ":streamGenerateContent"), srv.paths[0])
+}
@@ -2,10 +2,10 @@ package generator
 
 import (
 	"context"
+	"iter"
 	"time"
 
 	"github.com/shouni/gemini-image-kit/pkg/domain"
-	"github.com/shouni/go-gemini-client/pkg/gemini"
 	"google.golang.org/genai"
 )
 
@@ -19,14 +19,46 @@ type AssetManager interface {
 type ImageGenerator interface {
 	GenerateMangaPanel(ctx context.Context, req domain.ImageGenerationRequest) (*domain.ImageResponse, error)
 	GenerateMangaPage(ctx context.Context, req domain.ImagePageRequest) (*domain.ImageResponse, error)
+	EditImage(ctx context.Context, req domain.ImageEditRequest) (*domain.ImageResponse, error)
 }
 
 // ImageExecutor は、画像生成リクエストを処理し、画像関連データを準備するためのメソッドを定義するインターフェースです。
 type ImageExecutor interface {
 	// ExecuteRequest は、指定されたパラメータで画像生成リクエストを実行し、結果を返します。
-	ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts gemini.GenerateOptions) (*domain.ImageResponse, error)
+	ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error)
 	// PrepareImagePart は、指定された画像URLから後続処理で利用する画像パーツを作成します。
-	PrepareImagePart(ctx context.Context, rawURL string) *genai.Part
+	// 取得に失敗した場合や画像でない場合はエラーを返します。
+	PrepareImagePart(ctx context.Context, rawURL string) (*genai.Part, error)
+	// PrepareInlinePart は、メモリ上の画像データを URL 経由と同じ最適化処理にかけて画像パーツを作成します。
+	PrepareInlinePart(ctx context.Context, data []byte, mimeType string) (*genai.Part, error)
+}
+
+// StreamExecutor は、生成の途中経過を逐次返せる ImageExecutor です。
+// GeminiImageCore が実装しており、実装していない ImageExecutor では一括生成の結果のみが返されます。
+type StreamExecutor interface {
+	// ExecuteRequestStream は、思考テキスト・途中の画像・最終結果を届いた順に返します。
+	// 最終結果には ExecuteRequest と同じ FinishReason の検証が適用されます。
+	ExecuteRequestStream(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) iter.Seq2[domain.StreamEvent, error]
+}
+
+// MaskPreparer は、編集用のマスク画像を劣化させずに画像パーツへ変換できる ImageExecutor です。
+// GeminiImageCore が実装しており、実装していない ImageExecutor ではマスクも PrepareImagePart / PrepareInlinePart で準備されます。
+type MaskPreparer interface {
+	// PrepareMaskPart は、マスク画像を可逆なフォーマットの画像パーツに変換します。
+	PrepareMaskPart(ctx context.Context, uri domain.ImageURI) (*genai.Part, error)
+}
+
+// ContentModel は、genai.GenerateContentConfig をそのまま送信できる Gemini クライアントです。
+// GenAIClient が実装しています。aiClient がこれを実装していない場合、go-gemini-client が送信しない
+// 生成パラメータ (CandidateCount など) を指定したリクエストは ErrUnsupportedOption で失敗します。
+type ContentModel interface {
+	GenerateContentWithConfig(ctx context.Context, modelName string, parts []*genai.Part, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error)
+}
+
+// StreamingModel は、ストリーミング生成に対応した Gemini クライアントです。
+// GenAIClient が実装しています。aiClient がこれを実装していない場合、ExecuteRequestStream は一括生成にフォールバックします。
+type StreamingModel interface {
+	GenerateContentStream(ctx context.Context, modelName string, parts []*genai.Part, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error]
 }
 
 // ImageCacher は、画像をキャッシュするためのインターフェースです。
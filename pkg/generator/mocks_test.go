@@ -5,15 +5,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/shouni/gemini-image-kit/pkg/domain"
 	"github.com/shouni/go-gemini-client/pkg/gemini"
 	"google.golang.org/genai"
 )
 
+// mockImagePNG は画像として有効な最小限 (1x1) の PNG データです。
+var mockImagePNG = func() []byte {
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 1, 1)))
+	return buf.Bytes()
+}()
+
 // --- AI Client Mock ---
 
 const (
@@ -22,13 +32,15 @@ const (
 )
 
 type mockAIClient struct {
-	uploadCalled bool
-	deleteCalled bool
-	lastFileName string
+	uploadCalled   bool
+	deleteCalled   bool
+	lastFileName   string
+	lastUploadData []byte
 }
 
 func (m *mockAIClient) UploadFile(ctx context.Context, data []byte, mimeType, displayName string) (string, string, error) {
 	m.uploadCalled = true
+	m.lastUploadData = data
 	return MockFileUploadURI, MockFileUploadName, nil
 }
 
@@ -164,3 +176,47 @@ func (m *mockCache) Set(key string, value any, d time.Duration) {
 	}
 	m.data[key] = value
 }
+
+// --- ImageExecutor Mock ---
+
+type mockExecutor struct {
+	parts map[string]*genai.Part // ReferenceURL ごとに返すパーツ
+	errs  map[string]error       // ReferenceURL ごとに返すエラー
+
+	lastModel string
+	lastParts []*genai.Part
+	lastOpts  GenerateOptions
+	execErr   error
+
+	inlineCalls int
+}
+
+func (m *mockExecutor) ExecuteRequest(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) (*domain.ImageResponse, error) {
+	m.lastModel = model
+	m.lastParts = parts
+	m.lastOpts = opts
+	if m.execErr != nil {
+		return nil, m.execErr
+	}
+	return &domain.ImageResponse{
+		Data:     []byte("fake-image-bytes"),
+		MimeType: "image/png",
+		UsedSeed: domain.DereferenceSeed(opts.Seed),
+		Model:    model,
+	}, nil
+}
+
+func (m *mockExecutor) PrepareImagePart(ctx context.Context, rawURL string) (*genai.Part, error) {
+	if err, ok := m.errs[rawURL]; ok {
+		return nil, err
+	}
+	if part, ok := m.parts[rawURL]; ok {
+		return part, nil
+	}
+	return &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte(rawURL)}}, nil
+}
+
+func (m *mockExecutor) PrepareInlinePart(ctx context.Context, data []byte, mimeType string) (*genai.Part, error) {
+	m.inlineCalls++
+	return &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: data}}, nil
+}
@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/gemini-image-kit/pkg/imgutil"
+	"google.golang.org/genai"
+)
+
+// DefaultRequestPayloadBudget はインライン画像の合計サイズ上限 (バイト) のデフォルト値です。
+// リクエスト上限 (20MB) は Base64 エンコード後のサイズに適用されるため、余裕を持たせています。
+const DefaultRequestPayloadBudget = 14 << 20
+
+// fitPartsToBudget は InlineData パーツの合計サイズが budget 以下になるよう再圧縮します。
+// 小さいパーツから順に均等割りの枠を割り当て、余った枠は大きいパーツに回します。
+// 透過を含む画像は PNG のまま縮小します。FileData パーツは URI 参照のためペイロードに含めません。
+// 枠に収められなかったパーツは変更せず、そのインデックスと理由を返します。
+func fitPartsToBudget(parts []*genai.Part, budget int) map[int]error {
+	if budget <= 0 {
+		return nil
+	}
+
+	var inline []int
+	total := 0
+	for i, p := range parts {
+		if p != nil && p.InlineData != nil {
+			inline = append(inline, i)
+			total += len(p.InlineData.Data)
+		}
+	}
+	if total <= budget {
+		return nil
+	}
+
+	sort.SliceStable(inline, func(a, b int) bool {
+		return len(parts[inline[a]].InlineData.Data) < len(parts[inline[b]].InlineData.Data)
+	})
+
+	var unfit map[int]error
+	remaining := budget
+	for n, idx := range inline {
+		data := parts[idx].InlineData.Data
+		share := remaining / (len(inline) - n)
+		if len(data) > share {
+			compressed, err := imgutil.CompressToBudget(data, share)
+			if err == nil {
+				var format imgutil.Format
+				format, err = imgutil.DetectFormat(compressed)
+				if err == nil {
+					// 呼び出し元のパーツを書き換えないよう新しいパーツに差し替える
+					parts[idx] = &genai.Part{InlineData: &genai.Blob{MIMEType: format.MimeType(), Data: compressed}}
+					data = compressed
+				}
+			}
+			if err != nil {
+				// 除外される前提のため、このパーツの分の枠は残りのパーツに回す
+				if unfit == nil {
+					unfit = make(map[int]error)
+				}
+				unfit[idx] = fmt.Errorf("failed to fit image into %d bytes: %w", share, err)
+				continue
+			}
+		}
+		remaining -= len(data)
+	}
+	return unfit
+}
+
+// dropUnfitReferences は fitPartsToBudget で枠に収まらなかった参照画像を policy に従って除外します。
+// parts の先頭 len(refs.used) 件は refs.used と同じ順序で対応している必要があります。
+// それ以外のパーツ (修正元の画像など) が収まらなかった場合は常にエラーとします。
+func dropUnfitReferences(ctx context.Context, parts []*genai.Part, refs referenceReport, unfit map[int]error, policy domain.ReferencePolicy) ([]*genai.Part, referenceReport, error) {
+	if len(unfit) == 0 {
+		return parts, refs, nil
+	}
+
+	kept := make([]*genai.Part, 0, len(parts))
+	used := make([]domain.ImageURI, 0, len(refs.used))
+	for i, part := range parts {
+		err, ok := unfit[i]
+		if !ok {
+			kept = append(kept, part)
+			if i < len(refs.used) {
+				used = append(used, refs.used[i])
+			}
+			continue
+		}
+		if i >= len(refs.used) {
+			return nil, referenceReport{}, err
+		}
+		if err := skipReference(ctx, policy, refs.used[i], err, &refs); err != nil {
+			return nil, referenceReport{}, err
+		}
+	}
+	refs.used = used
+	return kept, refs, nil
+}
@@ -0,0 +1,119 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func noisePart(t *testing.T, size int) *genai.Part {
+	return noisePartWithAlpha(t, size, 255)
+}
+
+func noisePartWithAlpha(t *testing.T, size int, alpha uint8) *genai.Part {
+	t.Helper()
+	r := rand.New(rand.NewSource(int64(size)))
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			img.Set(x, y, color.NRGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), alpha})
+		}
+	}
+	buf := new(bytes.Buffer)
+	require.NoError(t, png.Encode(buf, img))
+	return &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: buf.Bytes()}}
+}
+
+func TestFitPartsToBudget(t *testing.T) {
+	t.Run("合計が上限以下の場合は何もしない", func(t *testing.T) {
+		part := noisePart(t, 32)
+		parts := []*genai.Part{part}
+
+		assert.Empty(t, fitPartsToBudget(parts, len(part.InlineData.Data)))
+		assert.Same(t, part, parts[0])
+	})
+
+	t.Run("上限を超える場合は全インライン画像で枠を分け合う", func(t *testing.T) {
+		small := noisePart(t, 16)
+		large1 := noisePart(t, 128)
+		large2 := noisePart(t, 128)
+		fileRef := &genai.Part{FileData: &genai.FileData{FileURI: "https://example.com/files/a"}}
+		parts := []*genai.Part{large1, fileRef, small, large2}
+
+		budget := len(small.InlineData.Data) + len(large1.InlineData.Data)/2
+		assert.Empty(t, fitPartsToBudget(parts, budget))
+
+		total := 0
+		for _, p := range parts {
+			if p.InlineData != nil {
+				total += len(p.InlineData.Data)
+			}
+		}
+		assert.LessOrEqual(t, total, budget)
+		assert.Same(t, small, parts[2], "small part should be kept as is")
+		assert.Same(t, fileRef, parts[1], "FileData part should not be touched")
+		assert.Equal(t, "image/jpeg", parts[0].InlineData.MIMEType)
+		assert.Equal(t, "image/png", large1.InlineData.MIMEType, "original part must not be mutated")
+	})
+	t.Run("透過を含む画像は PNG のまま縮小する", func(t *testing.T) {
+		lineArt := noisePartWithAlpha(t, 128, 128)
+		parts := []*genai.Part{lineArt}
+
+		budget := len(lineArt.InlineData.Data) / 2
+		assert.Empty(t, fitPartsToBudget(parts, budget))
+		assert.Equal(t, "image/png", parts[0].InlineData.MIMEType)
+		assert.LessOrEqual(t, len(parts[0].InlineData.Data), budget)
+	})
+
+	t.Run("収まらないパーツは変更せずに報告する", func(t *testing.T) {
+		large := noisePart(t, 128)
+		parts := []*genai.Part{large}
+
+		unfit := fitPartsToBudget(parts, 100)
+		assert.Contains(t, unfit, 0)
+		assert.Same(t, large, parts[0])
+	})
+}
+
+func TestDropUnfitReferences(t *testing.T) {
+	ctx := context.Background()
+	ok := domain.ImageURI{ReferenceURL: "gs://bucket/ok.png"}
+	tooLarge := domain.ImageURI{ReferenceURL: "gs://bucket/large.png"}
+	newInput := func() ([]*genai.Part, referenceReport, map[int]error) {
+		parts := []*genai.Part{noisePart(t, 16), noisePart(t, 128), {Text: "prompt"}}
+		refs := referenceReport{used: []domain.ImageURI{ok, tooLarge}}
+		return parts, refs, map[int]error{1: errors.New("too large")}
+	}
+
+	t.Run("WarnOnly では収まらない参照画像を除外して続行する", func(t *testing.T) {
+		parts, refs, unfit := newInput()
+		kept, report, err := dropUnfitReferences(ctx, parts, refs, unfit, domain.WarnOnly)
+		require.NoError(t, err)
+		assert.Len(t, kept, 2)
+		assert.Equal(t, []domain.ImageURI{ok}, report.used)
+		require.Len(t, report.skipped, 1)
+		assert.Equal(t, tooLarge, report.skipped[0].Image)
+	})
+
+	t.Run("FailOnMissingReference ではエラーを返す", func(t *testing.T) {
+		parts, refs, unfit := newInput()
+		_, _, err := dropUnfitReferences(ctx, parts, refs, unfit, domain.FailOnMissingReference)
+		assert.Error(t, err)
+	})
+
+	t.Run("参照画像以外のパーツが収まらない場合は常にエラー", func(t *testing.T) {
+		parts, refs, _ := newInput()
+		_, _, err := dropUnfitReferences(ctx, parts, refs, map[int]error{2: errors.New("too large")}, domain.SkipMissing)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+)
+
+// NegativePromptFormatter はプロンプトと否定プロンプトを1つのテキストに結合する方式です。
+// どちらも空の場合は空文字を返してください。
+type NegativePromptFormatter func(prompt, negative string) string
+
+// SeparatorNegativePrompt は "[Negative Prompt]" 見出しで区切って結合します。(デフォルト)
+func SeparatorNegativePrompt(prompt, negative string) string {
+	return buildFinalPrompt(prompt, negative)
+}
+
+// AvoidNegativePrompt は否定プロンプトを "Avoid: ..." という指示文として結合します。
+// 見出しによる区切りよりも指示文の方が従いやすいモデル向けです。
+func AvoidNegativePrompt(prompt, negative string) string {
+	n := strings.TrimSuffix(strings.TrimSpace(negative), ".")
+	if n == "" {
+		return strings.TrimSpace(prompt)
+	}
+	return joinNonEmpty("\n\n", prompt, "Avoid: "+n+".")
+}
+
+// WithNegativePromptFormatter は否定プロンプトの結合方式を設定します。
+func WithNegativePromptFormatter(f NegativePromptFormatter) GeneratorOption {
+	return func(g *GeminiGenerator) {
+		g.negativeFormat = f
+	}
+}
+
+// finalPrompt は設定された方式でプロンプトと否定プロンプトを結合します。
+func (g *GeminiGenerator) finalPrompt(prompt, negative string) string {
+	if g.negativeFormat != nil {
+		return g.negativeFormat(prompt, negative)
+	}
+	return buildFinalPrompt(prompt, negative)
+}
+
+// PromptTemplates は text/template によるプロンプトテンプレートの集合です。
+// 同じ集合に定義したテンプレートは {{template "name" .}} で部品 (パーシャル) として呼び出せます。
+// 変数が不足している場合は描画時にエラーになります。複数の goroutine から同時に使用できます。
+type PromptTemplates struct {
+	mu   sync.RWMutex
+	root *template.Template
+}
+
+// NewPromptTemplates は空の PromptTemplates を作成します。
+func NewPromptTemplates() *PromptTemplates {
+	return &PromptTemplates{
+		root: template.New("").Option("missingkey=error"),
+	}
+}
+
+// LoadPromptTemplates は fsys 内の patterns に一致するファイルをテンプレートとして読み込みます。
+// テンプレート名はファイル名 (例: "panel.tmpl") になります。
+func LoadPromptTemplates(fsys fs.FS, patterns ...string) (*PromptTemplates, error) {
+	t := NewPromptTemplates()
+	if _, err := t.root.ParseFS(fsys, patterns...); err != nil {
+		return nil, fmt.Errorf("failed to load prompt templates: %w", err)
+	}
+	return t, nil
+}
+
+// Define は name のテンプレートを定義します。同じ名前のテンプレートは上書きされます。
+func (t *PromptTemplates) Define(name, text string) error {
+	if name == "" {
+		return fmt.Errorf("template name is required")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.root.New(name).Parse(text); err != nil {
+		return fmt.Errorf("failed to parse prompt template %q: %w", name, err)
+	}
+	return nil
+}
+
+// Render は name のテンプレートを vars で描画します。
+func (t *PromptTemplates) Render(name string, vars map[string]any) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.root.Lookup(name) == nil {
+		return "", fmt.Errorf("unknown prompt template %q", name)
+	}
+
+	var sb strings.Builder
+	if err := t.root.ExecuteTemplate(&sb, name, vars); err != nil {
+		return "", fmt.Errorf("failed to render prompt template %q: %w", name, err)
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// WithPromptTemplates はリクエストの Template を描画するテンプレート集合を設定します。
+func WithPromptTemplates(t *PromptTemplates) GeneratorOption {
+	return func(g *GeminiGenerator) {
+		g.templates = t
+	}
+}
+
+// renderPrompt はリクエストの Template を描画し、その結果を Prompt の前に追加したリクエストを返します。
+func (g *GeminiGenerator) renderPrompt(req domain.ImagePageRequest) (domain.ImagePageRequest, error) {
+	if req.Template == "" {
+		return req, nil
+	}
+	if g.templates == nil {
+		return req, fmt.Errorf("%w: Template requires PromptTemplates", ErrMissingDependency)
+	}
+	rendered, err := g.templates.Render(req.Template, req.TemplateVars)
+	if err != nil {
+		return req, err
+	}
+	req.Prompt = joinNonEmpty("\n\n", rendered, req.Prompt)
+	return req, nil
+}
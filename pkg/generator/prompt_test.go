@@ -0,0 +1,114 @@
+package generator
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAvoidNegativePrompt(t *testing.T) {
+	assert.Equal(t, "a cat\n\nAvoid: blur, text.", AvoidNegativePrompt("a cat", " blur, text. "))
+	assert.Equal(t, "a cat", AvoidNegativePrompt("a cat", ""))
+	assert.Equal(t, "Avoid: blur.", AvoidNegativePrompt("", "blur"))
+	assert.Equal(t, "", AvoidNegativePrompt(" ", " "))
+	assert.Equal(t, buildFinalPrompt("a cat", "blur"), SeparatorNegativePrompt("a cat", "blur"))
+}
+
+func TestPromptTemplates(t *testing.T) {
+	t.Run("変数とパーシャルを展開する", func(t *testing.T) {
+		tmpl := NewPromptTemplates()
+		require.NoError(t, tmpl.Define("camera", "Camera: {{.Camera}}."))
+		require.NoError(t, tmpl.Define("panel", `{{.Scene}} with {{.Character}}. {{template "camera" .}}`))
+
+		got, err := tmpl.Render("panel", map[string]any{"Scene": "Rooftop at dusk", "Character": "Aoi", "Camera": "low angle"})
+		require.NoError(t, err)
+		assert.Equal(t, "Rooftop at dusk with Aoi. Camera: low angle.", got)
+	})
+
+	t.Run("未定義の関数を含むテンプレートは定義時にエラー", func(t *testing.T) {
+		assert.Error(t, NewPromptTemplates().Define("panel", "{{join .Characters}}"))
+	})
+
+	t.Run("変数が不足している場合はエラー", func(t *testing.T) {
+		tmpl := NewPromptTemplates()
+		require.NoError(t, tmpl.Define("panel", "{{.Scene}} / {{.Mood}}"))
+		_, err := tmpl.Render("panel", map[string]any{"Scene": "street"})
+		assert.ErrorContains(t, err, "Mood")
+	})
+
+	t.Run("未定義のテンプレートはエラー", func(t *testing.T) {
+		_, err := NewPromptTemplates().Render("missing", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("ファイルシステムから読み込める", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"prompts/panel.tmpl":  {Data: []byte(`{{.Scene}}. {{template "mood.tmpl" .}}`)},
+			"prompts/mood.tmpl":   {Data: []byte(`Mood: {{.Mood}}.`)},
+			"prompts/ignored.txt": {Data: []byte(`{{.Unused}}`)},
+		}
+		tmpl, err := LoadPromptTemplates(fsys, "prompts/*.tmpl")
+		require.NoError(t, err)
+
+		got, err := tmpl.Render("panel.tmpl", map[string]any{"Scene": "Classroom", "Mood": "tense"})
+		require.NoError(t, err)
+		assert.Equal(t, "Classroom. Mood: tense.", got)
+	})
+}
+
+func TestGeminiGenerator_PromptTemplates(t *testing.T) {
+	ctx := context.Background()
+	tmpl := NewPromptTemplates()
+	require.NoError(t, tmpl.Define("panel", "{{.Scene}}, {{.Camera}} shot."))
+
+	t.Run("テンプレートの描画結果がプロンプトの前に追加される", func(t *testing.T) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec, WithPromptTemplates(tmpl))
+		require.NoError(t, err)
+
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{
+			Prompt:       "Add rain.",
+			Template:     "panel",
+			TemplateVars: map[string]any{"Scene": "Harbor", "Camera": "wide"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Harbor, wide shot.\n\nAdd rain.", exec.lastParts[len(exec.lastParts)-1].Text)
+	})
+
+	t.Run("プロンプトが空でもテンプレートがあれば生成できる", func(t *testing.T) {
+		g, err := NewGeminiGenerator("model", "quality-model", &mockExecutor{}, WithPromptTemplates(tmpl))
+		require.NoError(t, err)
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{
+			Template:     "panel",
+			TemplateVars: map[string]any{"Scene": "Harbor", "Camera": "wide"},
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("変数の不足はエラー", func(t *testing.T) {
+		g, err := NewGeminiGenerator("model", "quality-model", &mockExecutor{}, WithPromptTemplates(tmpl))
+		require.NoError(t, err)
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Template: "panel", TemplateVars: map[string]any{"Scene": "Harbor"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("テンプレート集合が未設定の場合はエラー", func(t *testing.T) {
+		g, err := NewGeminiGenerator("model", "quality-model", &mockExecutor{})
+		require.NoError(t, err)
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Template: "panel"})
+		assert.ErrorIs(t, err, ErrMissingDependency)
+	})
+
+	t.Run("否定プロンプトの結合方式を切り替えられる", func(t *testing.T) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec, WithNegativePromptFormatter(AvoidNegativePrompt))
+		require.NoError(t, err)
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "a cat", NegativePrompt: "blur"})
+		require.NoError(t, err)
+		assert.Equal(t, "a cat\n\nAvoid: blur.", exec.lastParts[len(exec.lastParts)-1].Text)
+	})
+}
@@ -0,0 +1,168 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter は API 呼び出し前に送信枠を確保します。
+type RateLimiter interface {
+	// Acquire は model への1リクエスト (images 枚の画像生成) の送信枠を確保します。
+	// 枠が空くまで待機するか、待機しても確保できない場合は ErrQuotaExceeded を返します。
+	Acquire(ctx context.Context, model string, images int) error
+}
+
+// QuotaStore はトークンバケットの状態を保持するストアです。
+// 共有ストア (Redis など) で実装すると、複数のレプリカで同じ API キーの枠を分け合えます。
+type QuotaStore interface {
+	// Take は takes の全てのバケットから指定数のトークンをまとめて取り出します。
+	// いずれかのバケットが不足している場合はどのバケットからも取り出さずに ok=false を返します。
+	// waits には takes と同じ順に、各バケットから取り出せるようになるまでの待機時間 (不足していない場合は 0) を返します。
+	Take(ctx context.Context, takes []QuotaTake) (ok bool, waits []time.Duration, err error)
+}
+
+// QuotaTake は QuotaStore.Take で1つのバケットから取り出すトークン数です。
+// バケットは容量 Capacity で、Period かけて空から満タンまで連続的に補充されます。
+type QuotaTake struct {
+	Key      string
+	N        int
+	Capacity int
+	Period   time.Duration
+}
+
+// ModelQuota はモデルごとのクライアント側の上限です。0 は無制限を表します。
+type ModelQuota struct {
+	RequestsPerMinute int
+	// ImagesPerDay は直近24時間 (ローリングウィンドウ) の画像数の上限です。
+	// 枠は暦日の区切りでまとめて戻るのではなく、24時間かけて連続的に補充されます。
+	ImagesPerDay int
+}
+
+// WithRateLimiter は ExecuteRequest の各 API 呼び出し前に送信枠を確保するリミッターを設定します。
+func WithRateLimiter(l RateLimiter) CoreOption {
+	return func(c *GeminiImageCore) {
+		c.limiter = l
+	}
+}
+
+// TokenBucketLimiter はモデルごとに毎分のリクエスト数と直近24時間の画像数をトークンバケットで制限します。
+// 毎分の上限に達した場合は空くまで待機し、画像数の上限に達した場合は ErrQuotaExceeded を返します。
+// 両方の枠が空いている場合にのみトークンを消費するため、失敗した呼び出しが枠を減らすことはありません。
+// quotas に含まれないモデルは制限されません。
+type TokenBucketLimiter struct {
+	quotas map[string]ModelQuota
+	store  QuotaStore
+}
+
+// NewTokenBucketLimiter はモデル名をキーとした上限で TokenBucketLimiter を作成します。
+// store が nil の場合はプロセス内のメモリで管理します。
+func NewTokenBucketLimiter(quotas map[string]ModelQuota, store QuotaStore) *TokenBucketLimiter {
+	if store == nil {
+		store = NewMemoryQuotaStore()
+	}
+	return &TokenBucketLimiter{quotas: quotas, store: store}
+}
+
+// Acquire は RateLimiter インターフェースの実装です。
+func (l *TokenBucketLimiter) Acquire(ctx context.Context, model string, images int) error {
+	quota, ok := l.quotas[model]
+	if !ok {
+		return nil
+	}
+
+	var takes []QuotaTake
+	if quota.RequestsPerMinute > 0 {
+		takes = append(takes, QuotaTake{Key: "rpm:" + model, N: 1, Capacity: quota.RequestsPerMinute, Period: time.Minute})
+	}
+	daily := len(takes)
+	if quota.ImagesPerDay > 0 && images > 0 {
+		takes = append(takes, QuotaTake{Key: "ipd:" + model, N: images, Capacity: quota.ImagesPerDay, Period: 24 * time.Hour})
+	}
+	if len(takes) == 0 {
+		return nil
+	}
+
+	for {
+		ok, waits, err := l.store.Take(ctx, takes)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		// 画像数の枠は待機しても当面空かないため、毎分の枠を消費せずにエラーを返す
+		if daily < len(takes) && waits[daily] > 0 {
+			return fmt.Errorf("%w: image limit for %s reached (%d per rolling 24h)", ErrQuotaExceeded, model, quota.ImagesPerDay)
+		}
+		if err := sleepContext(ctx, waits[0]); err != nil {
+			return err
+		}
+	}
+}
+
+// MemoryQuotaStore はプロセス内でバケットを管理する QuotaStore の実装です。
+type MemoryQuotaStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryQuotaStore は新しい MemoryQuotaStore を作成します。
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Take は QuotaStore インターフェースの実装です。
+func (s *MemoryQuotaStore) Take(ctx context.Context, takes []QuotaTake) (bool, []time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	buckets := make([]*tokenBucket, len(takes))
+	waits := make([]time.Duration, len(takes))
+	ok := true
+	for i, t := range takes {
+		b, exists := s.buckets[t.Key]
+		if !exists {
+			b = &tokenBucket{tokens: float64(t.Capacity), last: now}
+			s.buckets[t.Key] = b
+		}
+
+		// 経過時間に応じてトークンを補充する
+		rate := float64(t.Capacity) / float64(t.Period)
+		b.tokens = min(float64(t.Capacity), b.tokens+rate*float64(now.Sub(b.last)))
+		b.last = now
+		buckets[i] = b
+
+		if b.tokens < float64(t.N) {
+			waits[i] = time.Duration((float64(t.N) - b.tokens) / rate)
+			ok = false
+		}
+	}
+	if !ok {
+		return false, waits, nil
+	}
+
+	for i, t := range takes {
+		buckets[i].tokens -= float64(t.N)
+	}
+	return true, waits, nil
+}
+
+// requestedImages は1リクエストで生成される画像数を返します。
+func requestedImages(opts GenerateOptions) int {
+	if opts.CandidateCount != nil && *opts.CandidateCount > 0 {
+		return int(*opts.CandidateCount)
+	}
+	return 1
+}
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// fakeClock はテスト用に時刻を手動で進める時計です。
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestMemoryQuotaStore_Take(t *testing.T) {
+	ctx := context.Background()
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	store := NewMemoryQuotaStore()
+	store.now = clock.Now
+	take := func(key string, n int) []QuotaTake {
+		return []QuotaTake{{Key: key, N: n, Capacity: 3, Period: time.Minute}}
+	}
+
+	for i := 0; i < 3; i++ {
+		ok, _, err := store.Take(ctx, take("k", 1))
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	ok, waits, err := store.Take(ctx, take("k", 1))
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []time.Duration{20 * time.Second}, waits)
+
+	clock.Advance(20 * time.Second)
+	ok, _, _ = store.Take(ctx, take("k", 1))
+	assert.True(t, ok, "token should be refilled")
+
+	ok, _, _ = store.Take(ctx, take("other", 1))
+	assert.True(t, ok, "keys must not share buckets")
+
+	t.Run("いずれかのバケットが不足している場合はどのバケットからも取り出さない", func(t *testing.T) {
+		ok, waits, err := store.Take(ctx, append(take("fresh", 1), take("other", 3)...))
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Zero(t, waits[0])
+		assert.Positive(t, waits[1])
+
+		ok, _, _ = store.Take(ctx, take("fresh", 3))
+		assert.True(t, ok, "the sufficient bucket must not be consumed by a failed take")
+	})
+}
+
+func TestTokenBucketLimiter_Acquire(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("1日の画像数を超えると ErrQuotaExceeded", func(t *testing.T) {
+		l := NewTokenBucketLimiter(map[string]ModelQuota{"quality": {ImagesPerDay: 3}}, nil)
+		require.NoError(t, l.Acquire(ctx, "quality", 2))
+		assert.ErrorIs(t, l.Acquire(ctx, "quality", 2), ErrQuotaExceeded)
+		assert.NoError(t, l.Acquire(ctx, "quality", 1))
+	})
+
+	t.Run("画像数の上限に達した場合は毎分の枠を消費しない", func(t *testing.T) {
+		l := NewTokenBucketLimiter(map[string]ModelQuota{"quality": {RequestsPerMinute: 1, ImagesPerDay: 1}}, nil)
+		require.NoError(t, l.Acquire(ctx, "quality", 1))
+
+		store := l.store.(*MemoryQuotaStore)
+		store.buckets["rpm:quality"].tokens = 1
+		assert.ErrorIs(t, l.Acquire(ctx, "quality", 1), ErrQuotaExceeded)
+		assert.InDelta(t, 1, store.buckets["rpm:quality"].tokens, 0.01)
+	})
+
+	t.Run("上限が未設定のモデルは制限しない", func(t *testing.T) {
+		l := NewTokenBucketLimiter(map[string]ModelQuota{"quality": {ImagesPerDay: 1}}, nil)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, l.Acquire(ctx, "fast", 1))
+		}
+	})
+
+	t.Run("毎分の上限に達すると補充まで待機する", func(t *testing.T) {
+		l := NewTokenBucketLimiter(map[string]ModelQuota{"fast": {RequestsPerMinute: 1200}}, nil)
+		start := time.Now()
+		for i := 0; i < 1201; i++ {
+			require.NoError(t, l.Acquire(ctx, "fast", 1))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("待機中のキャンセルでコンテキストのエラーを返す", func(t *testing.T) {
+		l := NewTokenBucketLimiter(map[string]ModelQuota{"fast": {RequestsPerMinute: 1}}, nil)
+		require.NoError(t, l.Acquire(ctx, "fast", 1))
+
+		cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, l.Acquire(cctx, "fast", 1), context.DeadlineExceeded)
+	})
+
+	t.Run("共有ストアを使うリミッター間で枠を分け合う", func(t *testing.T) {
+		store := NewMemoryQuotaStore()
+		quotas := map[string]ModelQuota{"quality": {ImagesPerDay: 2}}
+		a := NewTokenBucketLimiter(quotas, store)
+		b := NewTokenBucketLimiter(quotas, store)
+
+		require.NoError(t, a.Acquire(ctx, "quality", 1))
+		require.NoError(t, b.Acquire(ctx, "quality", 1))
+		assert.ErrorIs(t, a.Acquire(ctx, "quality", 1), ErrQuotaExceeded)
+	})
+}
+
+func TestGeminiImageCore_ExecuteRequest_RateLimit(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewTokenBucketLimiter(map[string]ModelQuota{"model": {ImagesPerDay: 3}}, nil)
+	srv := &genaiTestServer{respond: respondJSON(&genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{imageCandidate(0, "first"), imageCandidate(1, "second")},
+	})}
+	core, err := NewGeminiImageCore(newTestGenAIClient(t, srv), &mockReader{}, &mockHTTPClient{}, nil, time.Hour,
+		WithRateLimiter(limiter),
+		WithRetryPolicy(DefaultRetryPolicy()),
+	)
+	require.NoError(t, err)
+
+	_, err = core.ExecuteRequest(ctx, "model", nil, GenerateOptions{CandidateCount: genai.Ptr[int32](2)})
+	require.NoError(t, err)
+
+	_, err = core.ExecuteRequest(ctx, "model", nil, GenerateOptions{CandidateCount: genai.Ptr[int32](2)})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Len(t, srv.requests, 1, "client-side quota errors must not reach the API or be retried")
+}
@@ -0,0 +1,177 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"google.golang.org/genai"
+)
+
+// RetryClass はリトライ対象とするエラーの分類です。ビットの組み合わせで指定します。
+type RetryClass int
+
+const (
+	RetryRateLimit   RetryClass = 1 << iota // 429 / RESOURCE_EXHAUSTED
+	RetryServerError                        // 5xx (503 UNAVAILABLE など)
+	RetryFinishOther                        // FinishReason が OTHER で中断された場合
+	RetryNoImage                            // 空のレスポンス、または画像が含まれない場合
+
+	RetryAll = RetryRateLimit | RetryServerError | RetryFinishOther | RetryNoImage
+)
+
+// RetryPolicy は ExecuteRequest の失敗時に再試行する方針を定義します。
+// Gemini クライアント自体の再試行とは独立に、分類済みのエラーに対して適用されます。
+type RetryPolicy struct {
+	MaxAttempts    int           // 初回を含む最大試行回数 (1 以下はリトライなし)
+	InitialBackoff time.Duration // 初回リトライ前の待機時間
+	MaxBackoff     time.Duration // 待機時間の上限 (0 は無制限, ジッターと RetryInfo の待機時間にも適用)
+	Multiplier     float64       // 試行ごとの待機時間の倍率 (1 未満は 2)
+	Jitter         float64       // 待機時間に加える揺らぎの割合 (0-1)
+	RetryOn        RetryClass    // リトライ対象のエラー分類
+	ReseedOnRetry  bool          // リトライ時に新しいシードを使用する
+}
+
+// DefaultRetryPolicy は最大3回、1秒からの指数バックオフで全ての一時的なエラーを再試行するポリシーを返します。
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryOn:        RetryAll,
+	}
+}
+
+// WithRetryPolicy は ExecuteRequest の再試行ポリシーを設定します。
+// 未指定の場合は再試行しません。
+func WithRetryPolicy(p RetryPolicy) CoreOption {
+	return func(c *GeminiImageCore) {
+		c.retry = p
+	}
+}
+
+// shouldRetry はエラーがポリシーのリトライ対象かどうかを判定します。
+func (p RetryPolicy) shouldRetry(err error) bool {
+	return p.RetryOn&retryClassOf(err) != 0
+}
+
+// backoff は attempt 回目 (1 始まり) の失敗後に待機する時間を計算します。
+// サーバーが再試行までの時間を指定している場合はそちらを優先します。いずれの場合も MaxBackoff を超えません。
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	if d, ok := retryAfter(err); ok {
+		return p.capBackoff(float64(d))
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	d := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		d *= 1 + p.Jitter*(rand.Float64()*2-1)
+	}
+	return p.capBackoff(d)
+}
+
+// capBackoff は待機時間を MaxBackoff で切り詰めます。
+func (p RetryPolicy) capBackoff(d float64) time.Duration {
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+// RetryError は ExecuteRequest が成功しなかった場合に、それまでの全ての試行を保持するエラーです。
+// errors.As で取り出せ、Unwrap で最後の試行のエラー (またはキャンセル時のコンテキストのエラー) を返します。
+type RetryError struct {
+	Attempts []domain.GenerationAttempt
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	if len(e.Attempts) > 1 {
+		return fmt.Sprintf("failed after %d attempts: %v", len(e.Attempts), e.Err)
+	}
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// retryClassOf はエラーをリトライ分類に対応付けます。該当しない場合は 0 を返します。
+func retryClassOf(err error) RetryClass {
+	if err == nil {
+		return 0
+	}
+	if errors.Is(err, ErrQuotaExceeded) {
+		return RetryRateLimit
+	}
+
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) && apiErr.Code >= http.StatusInternalServerError {
+		return RetryServerError
+	}
+
+	if errors.Is(err, ErrNoImageInResponse) || errors.Is(err, ErrEmptyResponse) {
+		return RetryNoImage
+	}
+
+	var genErr *GenerationError
+	if errors.As(err, &genErr) && genErr.FinishReason == genai.FinishReasonOther {
+		return RetryFinishOther
+	}
+	// クライアント側で検証された場合、理由はメッセージにのみ含まれる
+	var respErr *gemini.APIResponseError
+	if errors.As(err, &respErr) && strings.Contains(respErr.Error(), string(genai.FinishReasonOther)) {
+		return RetryFinishOther
+	}
+	return 0
+}
+
+// retryAfter は APIError の詳細 (google.rpc.RetryInfo) から再試行までの待機時間を取り出します。
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	for _, detail := range apiErr.Details {
+		typ, _ := detail["@type"].(string)
+		if !strings.HasSuffix(typ, "RetryInfo") {
+			continue
+		}
+		delay, _ := detail["retryDelay"].(string)
+		if d, err := time.ParseDuration(delay); err == nil && d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// newRetrySeed はリトライ時に使用する新しいシードを生成します。
+// クライアントは int32 の範囲のシードのみ受け付けるため、その範囲で生成します。
+func newRetrySeed() int64 {
+	return int64(rand.Int32())
+}
+
+// sleepContext は ctx がキャンセルされるまで最大 d だけ待機します。
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
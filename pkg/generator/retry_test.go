@@ -0,0 +1,167 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// scriptedAIClient は指定した順にエラーを返し、尽きたら成功するクライアントです。
+type scriptedAIClient struct {
+	mockAIClient
+	errs  []error
+	calls int
+	seeds []*int64
+}
+
+func (s *scriptedAIClient) GenerateWithParts(ctx context.Context, model string, parts []*genai.Part, opts gemini.GenerateOptions) (*gemini.Response, error) {
+	s.calls++
+	s.seeds = append(s.seeds, opts.Seed)
+	if len(s.errs) > 0 {
+		err := s.errs[0]
+		s.errs = s.errs[1:]
+		return nil, err
+	}
+	return s.mockAIClient.GenerateWithParts(ctx, model, parts, opts)
+}
+
+func TestGeminiImageCore_ExecuteRequest_Retry(t *testing.T) {
+	ctx := context.Background()
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, RetryOn: RetryAll}
+	unavailable := genai.APIError{Code: 503, Status: "UNAVAILABLE"}
+
+	newCore := func(t *testing.T, ai gemini.GenerativeModel, p RetryPolicy) *GeminiImageCore {
+		core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{}, nil, time.Hour, WithRetryPolicy(p))
+		require.NoError(t, err)
+		return core
+	}
+
+	t.Run("一時的なエラーは再試行し、全ての試行を記録する", func(t *testing.T) {
+		ai := &scriptedAIClient{errs: []error{unavailable, genai.APIError{Code: 429}}}
+		resp, err := newCore(t, ai, policy).ExecuteRequest(ctx, "model", nil, GenerateOptions{})
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, ai.calls)
+		require.Len(t, resp.Attempts, 3)
+		assert.NotEmpty(t, resp.Attempts[0].Err)
+		assert.Empty(t, resp.Attempts[2].Err)
+		assert.Equal(t, 3, resp.Attempts[2].Number)
+	})
+
+	t.Run("リトライ対象外のエラーは即座に返す", func(t *testing.T) {
+		ai := &scriptedAIClient{errs: []error{unavailable}}
+		p := policy
+		p.RetryOn = RetryRateLimit
+		_, err := newCore(t, ai, p).ExecuteRequest(ctx, "model", nil, GenerateOptions{})
+		assert.Error(t, err)
+		assert.Equal(t, 1, ai.calls)
+	})
+
+	t.Run("最大試行回数に達したら最後のエラーを返す", func(t *testing.T) {
+		ai := &scriptedAIClient{errs: []error{unavailable, unavailable, genai.APIError{Code: 429}}}
+		_, err := newCore(t, ai, policy).ExecuteRequest(ctx, "model", nil, GenerateOptions{})
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+		assert.Equal(t, 3, ai.calls)
+
+		var retryErr *RetryError
+		require.ErrorAs(t, err, &retryErr)
+		require.Len(t, retryErr.Attempts, 3)
+		assert.Equal(t, 3, retryErr.Attempts[2].Number)
+		assert.NotEmpty(t, retryErr.Attempts[2].Err)
+		assert.Contains(t, err.Error(), "failed after 3 attempts")
+	})
+
+	t.Run("RetryInfo の待機時間を優先する", func(t *testing.T) {
+		limited := genai.APIError{Code: 429, Details: []map[string]any{
+			{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "0.02s"},
+		}}
+		ai := &scriptedAIClient{errs: []error{limited}}
+		resp, err := newCore(t, ai, policy).ExecuteRequest(ctx, "model", nil, GenerateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 20*time.Millisecond, resp.Attempts[1].Delay)
+	})
+
+	t.Run("ReseedOnRetry ではリトライごとに新しいシードを使う", func(t *testing.T) {
+		ai := &scriptedAIClient{errs: []error{unavailable}}
+		p := policy
+		p.ReseedOnRetry = true
+		seed := int64(7)
+		resp, err := newCore(t, ai, p).ExecuteRequest(ctx, "model", nil, GenerateOptions{Seed: &seed})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(7), *ai.seeds[0])
+		require.NotNil(t, ai.seeds[1])
+		assert.Equal(t, *ai.seeds[1], resp.UsedSeed)
+		assert.Equal(t, resp.UsedSeed, resp.Attempts[1].Seed)
+	})
+
+	t.Run("待機中のキャンセルでコンテキストのエラーを返す", func(t *testing.T) {
+		ai := &scriptedAIClient{errs: []error{unavailable}}
+		p := policy
+		p.InitialBackoff = time.Hour
+		cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := newCore(t, ai, p).ExecuteRequest(cctx, "model", nil, GenerateOptions{})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		var retryErr *RetryError
+		require.ErrorAs(t, err, &retryErr)
+		assert.Len(t, retryErr.Attempts, 1)
+	})
+
+	t.Run("RetryInfo の待機時間も MaxBackoff を超えない", func(t *testing.T) {
+		limited := genai.APIError{Code: 429, Details: []map[string]any{
+			{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "3600s"},
+		}}
+		ai := &scriptedAIClient{errs: []error{limited}}
+		p := policy
+		p.MaxBackoff = 5 * time.Millisecond
+		resp, err := newCore(t, ai, p).ExecuteRequest(ctx, "model", nil, GenerateOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, 5*time.Millisecond, resp.Attempts[1].Delay)
+	})
+}
+
+func TestRetryClassOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want RetryClass
+	}{
+		{"レート制限", classifyClientError(genai.APIError{Code: 429}), RetryRateLimit},
+		{"サーバーエラー", genai.APIError{Code: 503}, RetryServerError},
+		{"FinishReason OTHER", &GenerationError{Kind: ErrGenerationStopped, FinishReason: genai.FinishReasonOther}, RetryFinishOther},
+		{"画像なし", &GenerationError{Kind: ErrNoImageInResponse}, RetryNoImage},
+		{"安全フィルター", &GenerationError{Kind: ErrSafetyBlocked, FinishReason: genai.FinishReasonSafety}, 0},
+		{"不明なエラー", errors.New("boom"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, retryClassOf(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Multiplier: 2, Jitter: 0.5}
+	err := errors.New("boom")
+
+	for i := 0; i < 20; i++ {
+		d := p.backoff(1, err)
+		assert.GreaterOrEqual(t, d, 50*time.Millisecond)
+		assert.LessOrEqual(t, d, 150*time.Millisecond)
+	}
+	for i := 0; i < 20; i++ {
+		assert.LessOrEqual(t, p.backoff(5, err), 300*time.Millisecond, "jitter must not exceed MaxBackoff")
+	}
+
+	p.Jitter = 0
+	assert.Equal(t, 200*time.Millisecond, p.backoff(2, err))
+	assert.Equal(t, 300*time.Millisecond, p.backoff(3, err))
+}
@@ -0,0 +1,127 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"google.golang.org/genai"
+)
+
+// blockingFinishReasons はセーフティフィルターによる中断を表す FinishReason です。
+var blockingFinishReasons = []genai.FinishReason{
+	genai.FinishReasonImageSafety,
+	genai.FinishReasonImageProhibitedContent,
+	genai.FinishReasonProhibitedContent,
+	genai.FinishReasonBlocklist,
+	genai.FinishReasonSPII,
+	genai.FinishReasonSafety,
+}
+
+// WithSafetySettings はジェネレーターのデフォルトのブロックしきい値を設定します。
+// リクエストの GenerationConfig.SafetySettings に同じカテゴリがある場合は、リクエスト側が優先されます。
+func WithSafetySettings(settings ...domain.SafetySetting) GeneratorOption {
+	return func(g *GeminiGenerator) {
+		g.safetySettings = settings
+	}
+}
+
+// mergeSafetySettings はデフォルトの設定にリクエストの設定をカテゴリ単位で上書きします。
+func mergeSafetySettings(defaults, overrides []domain.SafetySetting) []domain.SafetySetting {
+	if len(overrides) == 0 {
+		return defaults
+	}
+	merged := make([]domain.SafetySetting, 0, len(defaults)+len(overrides))
+	overridden := make(map[string]bool, len(overrides))
+	for _, s := range overrides {
+		overridden[s.Category] = true
+	}
+	for _, s := range defaults {
+		if !overridden[s.Category] {
+			merged = append(merged, s)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+// toGenaiSafetySettings はドメインのしきい値設定を SDK の型に変換します。
+func toGenaiSafetySettings(src []domain.SafetySetting) []*genai.SafetySetting {
+	if len(src) == 0 {
+		return nil
+	}
+	settings := make([]*genai.SafetySetting, 0, len(src))
+	for _, s := range src {
+		settings = append(settings, &genai.SafetySetting{
+			Category:  genai.HarmCategory(s.Category),
+			Threshold: genai.HarmBlockThreshold(s.Threshold),
+		})
+	}
+	return settings
+}
+
+// SafetyReport は err がセーフティフィルターによるブロックの場合、その詳細を返します。
+// 原因カテゴリと確率 (Triggered / Ratings) は、レスポンスをそのまま返す ContentModel クライアント (GenAIClient) を
+// 使用した場合にのみ設定されます。go-gemini-client の Client はブロックされた先頭の候補を文字列のみのエラーに
+// 変換するため FinishReason とメッセージのみとなり、プロンプト自体のブロックは ErrEmptyResponse に分類されるため
+// レポートは返されません。
+func SafetyReport(err error) (*domain.SafetyBlockReport, bool) {
+	if !errors.Is(err, ErrSafetyBlocked) {
+		return nil, false
+	}
+
+	var genErr *GenerationError
+	if errors.As(err, &genErr) {
+		return genErr.safetyReport(), true
+	}
+
+	report := &domain.SafetyBlockReport{}
+	var respErr *gemini.APIResponseError
+	if errors.As(err, &respErr) {
+		report.Message = respErr.Error()
+		for _, reason := range blockingFinishReasons {
+			if strings.Contains(report.Message, string(reason)) {
+				report.FinishReason = string(reason)
+				break
+			}
+		}
+	}
+	return report, true
+}
+
+// safetyReport は GenerationError の内容をモデレーション用のレポートに変換します。
+func (e *GenerationError) safetyReport() *domain.SafetyBlockReport {
+	report := &domain.SafetyBlockReport{
+		FinishReason: string(e.FinishReason),
+		Message:      e.FinishMessage,
+		Ratings:      toDomainSafetyRatings(e.SafetyRatings),
+	}
+	if e.PromptFeedback != nil {
+		report.BlockReason = string(e.PromptFeedback.BlockReason)
+		if report.Message == "" {
+			report.Message = e.PromptFeedback.BlockReasonMessage
+		}
+	}
+	report.Triggered = triggeredRatings(report.Ratings)
+	return report
+}
+
+// triggeredRatings はブロックの原因となった評価を返します。
+// API が Blocked を設定していない場合は、確率が HIGH の評価を原因とみなします。
+func triggeredRatings(ratings []domain.SafetyRating) []domain.SafetyRating {
+	var triggered []domain.SafetyRating
+	for _, r := range ratings {
+		if r.Blocked {
+			triggered = append(triggered, r)
+		}
+	}
+	if len(triggered) > 0 {
+		return triggered
+	}
+	for _, r := range ratings {
+		if r.Probability == string(genai.HarmProbabilityHigh) {
+			triggered = append(triggered, r)
+		}
+	}
+	return triggered
+}
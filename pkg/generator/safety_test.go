@@ -0,0 +1,105 @@
+package generator
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+func TestMergeSafetySettings(t *testing.T) {
+	defaults := []domain.SafetySetting{
+		{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_MEDIUM_AND_ABOVE"},
+		{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_MEDIUM_AND_ABOVE"},
+	}
+	overrides := []domain.SafetySetting{
+		{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_ONLY_HIGH"},
+	}
+
+	merged := mergeSafetySettings(defaults, overrides)
+	assert.ElementsMatch(t, []domain.SafetySetting{
+		{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_MEDIUM_AND_ABOVE"},
+		{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_ONLY_HIGH"},
+	}, merged)
+	assert.Equal(t, defaults, mergeSafetySettings(defaults, nil))
+}
+
+func TestGeminiGenerator_SafetySettings(t *testing.T) {
+	exec := &mockExecutor{}
+	g, err := NewGeminiGenerator("model", "quality-model", exec, WithSafetySettings(
+		domain.SafetySetting{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_MEDIUM_AND_ABOVE"},
+	))
+	require.NoError(t, err)
+
+	t.Run("デフォルトの設定がリクエストに適用される", func(t *testing.T) {
+		_, err := g.GenerateMangaPanel(context.Background(), domain.ImageGenerationRequest{Prompt: "action scene"})
+		require.NoError(t, err)
+		require.Len(t, exec.lastOpts.SafetySettings, 1)
+		assert.Equal(t, genai.HarmBlockThresholdBlockMediumAndAbove, exec.lastOpts.SafetySettings[0].Threshold)
+	})
+
+	t.Run("リクエストの設定が同じカテゴリのデフォルトを上書きする", func(t *testing.T) {
+		_, err := g.GenerateMangaPanel(context.Background(), domain.ImageGenerationRequest{
+			Prompt: "action scene",
+			GenerationConfig: &domain.GenerationConfig{SafetySettings: []domain.SafetySetting{
+				{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_ONLY_HIGH"},
+			}},
+		})
+		require.NoError(t, err)
+		require.Len(t, exec.lastOpts.SafetySettings, 1)
+		assert.Equal(t, genai.HarmBlockThresholdBlockOnlyHigh, exec.lastOpts.SafetySettings[0].Threshold)
+	})
+}
+
+func TestSafetyReport(t *testing.T) {
+	t.Run("候補のブロックからカテゴリと確率を報告する", func(t *testing.T) {
+		err := fmt.Errorf("panel 3: %w", newCandidateError(&genai.Candidate{
+			FinishReason: genai.FinishReasonImageSafety,
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryDangerousContent, Probability: genai.HarmProbabilityHigh, Blocked: true},
+				{Category: genai.HarmCategoryHarassment, Probability: genai.HarmProbabilityLow},
+			},
+		}, nil))
+
+		report, ok := SafetyReport(err)
+		require.True(t, ok)
+		assert.Equal(t, "IMAGE_SAFETY", report.FinishReason)
+		assert.Len(t, report.Ratings, 2)
+		assert.Equal(t, []domain.SafetyRating{
+			{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Probability: "HIGH", Blocked: true},
+		}, report.Triggered)
+		assert.Contains(t, err.Error(), "HARM_CATEGORY_DANGEROUS_CONTENT=HIGH")
+	})
+
+	t.Run("Blocked が設定されていない場合は確率 HIGH の評価を原因とする", func(t *testing.T) {
+		report, ok := SafetyReport(newCandidateError(&genai.Candidate{
+			FinishReason: genai.FinishReasonSafety,
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryHarassment, Probability: genai.HarmProbabilityHigh},
+				{Category: genai.HarmCategoryHateSpeech, Probability: genai.HarmProbabilityNegligible},
+			},
+		}, nil))
+		require.True(t, ok)
+		require.Len(t, report.Triggered, 1)
+		assert.Equal(t, "HARM_CATEGORY_HARASSMENT", report.Triggered[0].Category)
+	})
+
+	t.Run("プロンプトのブロックでは BlockReason を報告する", func(t *testing.T) {
+		report, ok := SafetyReport(newPromptBlockedError(&genai.GenerateContentResponsePromptFeedback{
+			BlockReason:        genai.BlockedReasonSafety,
+			BlockReasonMessage: "prompt blocked",
+		}))
+		require.True(t, ok)
+		assert.Equal(t, "SAFETY", report.BlockReason)
+		assert.Equal(t, "prompt blocked", report.Message)
+	})
+
+	t.Run("セーフティ以外のエラーではレポートを返さない", func(t *testing.T) {
+		_, ok := SafetyReport(newCandidateError(&genai.Candidate{FinishReason: genai.FinishReasonRecitation}, nil))
+		assert.False(t, ok)
+	})
+}
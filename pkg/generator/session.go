@@ -0,0 +1,233 @@
+package generator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"google.golang.org/genai"
+)
+
+// DefaultRefinementHistory はモデルに送り返す過去の指示の最大件数です。
+const DefaultRefinementHistory = 10
+
+// RefinementTurn は対話的な修正の1ターン分の記録です。
+type RefinementTurn struct {
+	Instruction string                 `json:"instruction"`
+	Image       *domain.GeneratedImage `json:"image,omitempty"`
+	UsedSeed    int64                  `json:"used_seed"`
+}
+
+// refinementState はセッションの永続化対象となる状態です。
+type refinementState struct {
+	Base       domain.ImagePageRequest `json:"base"`
+	Turns      []RefinementTurn        `json:"turns"`
+	MaxHistory int                     `json:"max_history"`
+}
+
+// RefinementSession は「空をもっと暗く」のような追加指示を、直前の生成結果を文脈として送り返しながら
+// 繰り返し適用するための会話セッションです。
+// MarshalJSON で状態を保存し、RestoreRefinementSession で別のリクエストから再開できます。
+type RefinementSession struct {
+	gen   *GeminiGenerator
+	mu    sync.Mutex
+	state refinementState
+}
+
+// NewRefinementSession は base の設定 (参照画像・システムプロンプト・サイズ等) を引き継ぐセッションを作成します。
+func (g *GeminiGenerator) NewRefinementSession(base domain.ImagePageRequest) *RefinementSession {
+	return &RefinementSession{
+		gen: g,
+		state: refinementState{
+			Base:       base,
+			MaxHistory: DefaultRefinementHistory,
+		},
+	}
+}
+
+// RestoreRefinementSession は MarshalJSON で保存した状態からセッションを復元します。
+func (g *GeminiGenerator) RestoreRefinementSession(data []byte) (*RefinementSession, error) {
+	var state refinementState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to restore refinement session: %w", err)
+	}
+	if state.MaxHistory <= 0 {
+		state.MaxHistory = DefaultRefinementHistory
+	}
+	return &RefinementSession{gen: g, state: state}, nil
+}
+
+// MarshalJSON はセッションの状態 (設定・履歴・直前の画像) を JSON にシリアライズします。
+// 画像は再開に必要な直前の1枚のみを保存し、それ以前のターンは指示とシードのみを保存します。
+func (s *RefinementSession) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state
+	state.Turns = make([]RefinementTurn, len(s.state.Turns))
+	latest := s.latestImage()
+	for i, t := range s.state.Turns {
+		if t.Image != latest {
+			t.Image = nil
+		}
+		state.Turns[i] = t
+	}
+	return json.Marshal(state)
+}
+
+// Turns はこれまでのターンの記録を返します。
+// RestoreRefinementSession で復元したセッションでは、直前のターン以外の Image は nil です。
+func (s *RefinementSession) Turns() []RefinementTurn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RefinementTurn(nil), s.state.Turns...)
+}
+
+// LatestImage は直前のターンで生成された画像を返します。まだ生成していない場合は nil を返します。
+func (s *RefinementSession) LatestImage() *domain.GeneratedImage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latestImage()
+}
+
+// Refine は指示を適用して画像を生成し、結果を履歴に追加します。
+// 最初のターンでは base のプロンプトに指示を加えて新規に生成し、
+// 以降のターンでは直前の画像と過去の指示を文脈として送り、その画像を修正させます。
+func (s *RefinementSession) Refine(ctx context.Context, instruction string) (*domain.ImageResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	instruction = strings.TrimSpace(instruction)
+	previous := s.latestImage()
+
+	var resp *domain.ImageResponse
+	var err error
+	if previous == nil {
+		req := s.state.Base
+		req.Prompt = joinNonEmpty("\n\n", req.Prompt, instruction)
+		resp, err = s.gen.GenerateMangaPage(ctx, req)
+	} else {
+		if instruction == "" {
+			return nil, fmt.Errorf("instruction cannot be empty")
+		}
+		resp, err = s.refine(ctx, previous, instruction)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	turn := RefinementTurn{Instruction: instruction, UsedSeed: resp.UsedSeed}
+	switch {
+	case len(resp.Images) > 0:
+		img := resp.Images[0]
+		turn.Image = &img
+	case len(resp.Data) > 0:
+		turn.Image = &domain.GeneratedImage{Data: resp.Data, MimeType: resp.MimeType}
+	}
+	s.state.Turns = append(s.state.Turns, turn)
+	return resp, nil
+}
+
+// refine は直前の画像と履歴を文脈にして修正リクエストを実行します。
+func (s *RefinementSession) refine(ctx context.Context, previous *domain.GeneratedImage, instruction string) (*domain.ImageResponse, error) {
+	base, err := s.gen.renderPrompt(s.state.Base)
+	if err != nil {
+		return nil, err
+	}
+	base, err = s.gen.applyStyle(base)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. 元の参照画像 (キャラクターの参照シートを含む)
+	sheets, characterBlock, err := s.gen.characterContext(ctx, base.CharacterIDs)
+	if err != nil {
+		return nil, err
+	}
+	parts, refs, err := s.gen.collectImageParts(ctx, append(sheets, base.Images...), base.ReferencePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. これまでの指示と直前の生成画像
+	history := joinNonEmpty("\n\n", characterBlock, s.historyText(base.Prompt))
+	parts = append(parts, &genai.Part{Text: history})
+	prevPart, err := s.gen.core.PrepareInlinePart(ctx, previous.Data, previous.MimeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare previous image: %w", err)
+	}
+	parts = append(parts, prevPart)
+
+	budget := base.PayloadBudget
+	if budget == 0 {
+		budget = DefaultRequestPayloadBudget
+	}
+	parts, refs, err = dropUnfitReferences(ctx, parts, refs, fitPartsToBudget(parts, budget), base.ReferencePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 今回の修正指示
+	prompt := "Revise the last image above as follows, keeping everything else unchanged: " + instruction
+	parts = append(parts, &genai.Part{Text: s.gen.finalPrompt(prompt, base.NegativePrompt)})
+
+	opts := s.gen.toOptions(base.AspectRatio, base.ImageSize, base.SystemPrompt, base.Seed, base.CandidateCount)
+	opts = s.gen.applyGenerationConfig(opts, base.GenerationConfig)
+	resp, err := s.gen.execute(ctx, withModelOverride(base.Model, s.gen.pageModels()), parts, opts)
+	if err != nil {
+		return nil, err
+	}
+	resp.UsedReferences = refs.used
+	resp.SkippedReferences = refs.skipped
+	return resp, nil
+}
+
+// historyText は元のプロンプトと直近 MaxHistory 件の指示を文章にまとめます。
+func (s *RefinementSession) historyText(original string) string {
+	var sb strings.Builder
+	sb.WriteString("Original request:\n")
+	sb.WriteString(strings.TrimSpace(original))
+
+	// 指示のない最初のターンは番号付けの対象外とする
+	var instructions []string
+	for _, t := range s.state.Turns {
+		if t.Instruction != "" {
+			instructions = append(instructions, t.Instruction)
+		}
+	}
+	if len(instructions) > s.state.MaxHistory {
+		instructions = instructions[len(instructions)-s.state.MaxHistory:]
+	}
+	if len(instructions) > 0 {
+		sb.WriteString("\n\nChanges requested so far:")
+		for i, instruction := range instructions {
+			fmt.Fprintf(&sb, "\n%d. %s", i+1, instruction)
+		}
+	}
+	sb.WriteString("\n\nThe image generated for the latest request follows.")
+	return sb.String()
+}
+
+// latestImage は最後に画像を生成したターンの画像を返します。呼び出し側でロックを保持してください。
+func (s *RefinementSession) latestImage() *domain.GeneratedImage {
+	for i := len(s.state.Turns) - 1; i >= 0; i-- {
+		if img := s.state.Turns[i].Image; img != nil {
+			return img
+		}
+	}
+	return nil
+}
+
+// joinNonEmpty は空でない文字列のみを sep で結合します。
+func joinNonEmpty(sep string, values ...string) string {
+	var parts []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, sep)
+}
@@ -0,0 +1,109 @@
+package generator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefinementSession(t *testing.T) {
+	ctx := context.Background()
+
+	newGenerator := func() (*GeminiGenerator, *mockExecutor) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		require.NoError(t, err)
+		return g, exec
+	}
+	base := domain.ImagePageRequest{
+		Prompt: "a city at dusk",
+		Images: []domain.ImageURI{{ReferenceURL: "http://example.com/ref.png"}},
+	}
+
+	t.Run("最初のターンは通常の生成として実行される", func(t *testing.T) {
+		g, exec := newGenerator()
+		s := g.NewRefinementSession(base)
+
+		_, err := s.Refine(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, exec.lastParts, 2)
+		assert.Equal(t, "a city at dusk", exec.lastParts[1].Text)
+		require.NotNil(t, s.LatestImage())
+		assert.Equal(t, []byte("fake-image-bytes"), s.LatestImage().Data)
+	})
+
+	t.Run("追加指示では直前の画像と履歴を送り返す", func(t *testing.T) {
+		g, exec := newGenerator()
+		s := g.NewRefinementSession(base)
+		_, err := s.Refine(ctx, "")
+		require.NoError(t, err)
+
+		_, err = s.Refine(ctx, "make the sky darker")
+		require.NoError(t, err)
+		_, err = s.Refine(ctx, "add rain")
+		require.NoError(t, err)
+
+		require.Len(t, exec.lastParts, 4)
+		assert.Equal(t, []byte("http://example.com/ref.png"), exec.lastParts[0].InlineData.Data)
+		assert.Contains(t, exec.lastParts[1].Text, "a city at dusk")
+		assert.Contains(t, exec.lastParts[1].Text, "Changes requested so far:\n1. make the sky darker\n\n")
+		assert.Equal(t, []byte("fake-image-bytes"), exec.lastParts[2].InlineData.Data)
+		assert.Contains(t, exec.lastParts[3].Text, "add rain")
+		assert.Equal(t, "quality-model", exec.lastModel)
+		assert.Len(t, s.Turns(), 3)
+	})
+
+	t.Run("指示のない最初のターンのみの場合は指示の見出しを含めない", func(t *testing.T) {
+		g, _ := newGenerator()
+		s := g.NewRefinementSession(base)
+		_, err := s.Refine(ctx, "")
+		require.NoError(t, err)
+
+		got := s.historyText(base.Prompt)
+		assert.NotContains(t, got, "Changes requested so far")
+	})
+
+	t.Run("2ターン目以降に空の指示はエラー", func(t *testing.T) {
+		g, _ := newGenerator()
+		s := g.NewRefinementSession(base)
+		_, err := s.Refine(ctx, "")
+		require.NoError(t, err)
+
+		_, err = s.Refine(ctx, "  ")
+		assert.Error(t, err)
+	})
+
+	t.Run("JSON で保存した状態から再開できる", func(t *testing.T) {
+		g, exec := newGenerator()
+		s := g.NewRefinementSession(base)
+		_, err := s.Refine(ctx, "")
+		require.NoError(t, err)
+		_, err = s.Refine(ctx, "make the sky darker")
+		require.NoError(t, err)
+
+		data, err := s.MarshalJSON()
+		require.NoError(t, err)
+
+		restored, err := g.RestoreRefinementSession(data)
+		require.NoError(t, err)
+		turns := restored.Turns()
+		require.Len(t, turns, 2)
+		assert.Nil(t, turns[0].Image, "only the latest image should be persisted")
+		assert.Equal(t, s.LatestImage(), restored.LatestImage())
+		assert.Equal(t, "make the sky darker", turns[1].Instruction)
+		assert.NotNil(t, s.Turns()[0].Image, "marshaling must not modify the live session")
+
+		_, err = restored.Refine(ctx, "add rain")
+		require.NoError(t, err)
+		assert.Contains(t, exec.lastParts[1].Text, "make the sky darker")
+	})
+
+	t.Run("不正な JSON の復元はエラー", func(t *testing.T) {
+		g, _ := newGenerator()
+		_, err := g.RestoreRefinementSession([]byte("{"))
+		assert.Error(t, err)
+	})
+}
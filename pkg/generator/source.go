@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dataURIScheme = "data:"
+	fileURIScheme = "file://"
+)
+
+// ErrLocalAccessDenied はローカルファイルの参照が許可されていない場合のエラーです。
+var ErrLocalAccessDenied = errors.New("local file access is not allowed")
+
+// isDataURI は RFC 2397 の data: URI かどうかを判定します。
+func isDataURI(rawURL string) bool {
+	return strings.HasPrefix(rawURL, dataURIScheme)
+}
+
+// sourceKey はキャッシュキーに使用する参照元の識別子を返します。
+// data: URI はペイロード全体がキーに入るのを避けるため、SHA-256 ハッシュに置き換えます。
+func sourceKey(rawURL string) string {
+	if !isDataURI(rawURL) {
+		return rawURL
+	}
+	sum := sha256.Sum256([]byte(rawURL))
+	return dataURIScheme + "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// isLocalPath は file:// URI、またはスキームを持たないパスかどうかを判定します。
+func isLocalPath(rawURL string) bool {
+	return strings.HasPrefix(rawURL, fileURIScheme) || !strings.Contains(rawURL, "://")
+}
+
+// decodeDataURI は data:[<mediatype>][;base64],<data> 形式の URI からデータを取り出します。
+func decodeDataURI(rawURL string) ([]byte, error) {
+	meta, payload, ok := strings.Cut(strings.TrimPrefix(rawURL, dataURIScheme), ",")
+	if !ok {
+		return nil, fmt.Errorf("malformed data URI: missing comma")
+	}
+
+	if strings.HasSuffix(meta, ";base64") {
+		data, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			// パディングが省略されているケースも許容する
+			if data, rawErr := base64.RawStdEncoding.DecodeString(payload); rawErr == nil {
+				return data, nil
+			}
+			return nil, fmt.Errorf("malformed data URI: %w", err)
+		}
+		return data, nil
+	}
+
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, fmt.Errorf("malformed data URI: %w", err)
+	}
+	return []byte(decoded), nil
+}
+
+// readLocalFile は許可されたルートディレクトリ配下のファイルを読み込みます。
+// os.Root を使用するため、".." やシンボリックリンクによるルート外への参照は拒否されます。
+func readLocalFile(root, rawURL string) ([]byte, error) {
+	if root == "" {
+		return nil, ErrLocalAccessDenied
+	}
+
+	path := rawURL
+	if strings.HasPrefix(rawURL, fileURIScheme) {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
+		path = u.Path
+	}
+
+	rel := path
+	if filepath.IsAbs(path) {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err = filepath.Rel(absRoot, path); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrLocalAccessDenied, path)
+		}
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%w: %s is outside of %s", ErrLocalAccessDenied, path, root)
+	}
+
+	r, err := os.OpenRoot(root)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	f, err := r.Open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
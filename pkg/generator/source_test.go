@@ -0,0 +1,112 @@
+package generator
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeDataURI(t *testing.T) {
+	t.Run("base64 形式をデコードできる", func(t *testing.T) {
+		uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(mockImagePNG)
+		got, err := decodeDataURI(uri)
+		require.NoError(t, err)
+		assert.Equal(t, mockImagePNG, got)
+	})
+
+	t.Run("パーセントエンコード形式をデコードできる", func(t *testing.T) {
+		got, err := decodeDataURI("data:,hello%20world")
+		require.NoError(t, err)
+		assert.Equal(t, "hello world", string(got))
+	})
+
+	t.Run("カンマがない場合はエラー", func(t *testing.T) {
+		_, err := decodeDataURI("data:image/png;base64")
+		assert.Error(t, err)
+	})
+}
+
+func TestSourceKey(t *testing.T) {
+	uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(mockImagePNG)
+
+	key := sourceKey(uri)
+	assert.True(t, strings.HasPrefix(key, "data:sha256:"), key)
+	assert.NotContains(t, key, base64.StdEncoding.EncodeToString(mockImagePNG))
+	assert.Equal(t, key, sourceKey(uri), "the same payload must map to the same key")
+	assert.NotEqual(t, key, sourceKey("data:,other"))
+	assert.Equal(t, "gs://bucket/hero.png", sourceKey("gs://bucket/hero.png"))
+}
+
+func TestReadLocalFile(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "chars"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "chars", "hero.png"), mockImagePNG, 0o644))
+
+	outside := filepath.Join(t.TempDir(), "secret.png")
+	require.NoError(t, os.WriteFile(outside, []byte("secret"), 0o644))
+
+	t.Run("ルート配下の相対パスを読み込める", func(t *testing.T) {
+		got, err := readLocalFile(root, "chars/hero.png")
+		require.NoError(t, err)
+		assert.Equal(t, mockImagePNG, got)
+	})
+
+	t.Run("file:// URI を読み込める", func(t *testing.T) {
+		got, err := readLocalFile(root, "file://"+filepath.Join(root, "chars", "hero.png"))
+		require.NoError(t, err)
+		assert.Equal(t, mockImagePNG, got)
+	})
+
+	t.Run("ルート外の絶対パスは拒否される", func(t *testing.T) {
+		_, err := readLocalFile(root, outside)
+		assert.ErrorIs(t, err, ErrLocalAccessDenied)
+	})
+
+	t.Run("親ディレクトリを辿るパスは拒否される", func(t *testing.T) {
+		_, err := readLocalFile(root, "../secret.png")
+		assert.ErrorIs(t, err, ErrLocalAccessDenied)
+	})
+
+	t.Run("ルート未設定の場合は拒否される", func(t *testing.T) {
+		_, err := readLocalFile("", "chars/hero.png")
+		assert.ErrorIs(t, err, ErrLocalAccessDenied)
+	})
+}
+
+func TestGeminiImageCore_FetchImageData_Sources(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "ref.png"), mockImagePNG, 0o644))
+
+	core := &GeminiImageCore{
+		httpClient: &mockHTTPClient{},
+		reader:     &mockReader{},
+		localRoot:  root,
+	}
+
+	t.Run("data: URI から画像パーツを作成できる", func(t *testing.T) {
+		uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(mockImagePNG)
+		part, err := core.PrepareImagePart(ctx, uri)
+		require.NoError(t, err)
+		require.NotNil(t, part.InlineData)
+		assert.Equal(t, "image/png", part.InlineData.MIMEType)
+	})
+
+	t.Run("ローカルパスから画像パーツを作成できる", func(t *testing.T) {
+		part, err := core.PrepareImagePart(ctx, "ref.png")
+		require.NoError(t, err)
+		assert.Equal(t, mockImagePNG, part.InlineData.Data)
+	})
+
+	t.Run("ルート外のローカルパスは ErrAssetFetch として扱われる", func(t *testing.T) {
+		_, err := core.PrepareImagePart(ctx, "/etc/passwd")
+		assert.ErrorIs(t, err, ErrAssetFetch)
+		assert.ErrorIs(t, err, ErrLocalAccessDenied)
+	})
+}
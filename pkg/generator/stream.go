@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+	"sort"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"google.golang.org/genai"
+)
+
+// ExecuteRequestStream は Gemini API のストリーミング生成を呼び出し、届いた順にイベントを返します。(StreamExecutor インターフェース実装)
+// 受信したチャンクは結合され、最後に ParseToResponse と同じ検証を経た最終結果 (StreamEventFinal) が返されます。
+// aiClient が StreamingModel を実装していない場合 (go-gemini-client の Client など) は ExecuteRequest の結果のみを返します。
+// 途中経過を送出した後は再試行できないため、ストリーミングでは RetryPolicy は適用されません。
+func (c *GeminiImageCore) ExecuteRequestStream(ctx context.Context, model string, parts []*genai.Part, opts GenerateOptions) iter.Seq2[domain.StreamEvent, error] {
+	return func(yield func(domain.StreamEvent, error) bool) {
+		streamer, ok := c.aiClient.(StreamingModel)
+		if !ok {
+			resp, err := c.ExecuteRequest(ctx, model, parts, opts)
+			if err != nil {
+				yield(domain.StreamEvent{}, err)
+				return
+			}
+			yield(domain.StreamEvent{Kind: domain.StreamEventFinal, Response: resp}, nil)
+			return
+		}
+
+		if c.limiter != nil {
+			if err := c.limiter.Acquire(ctx, model, requestedImages(opts)); err != nil {
+				yield(domain.StreamEvent{}, err)
+				return
+			}
+		}
+
+		acc := &streamAccumulator{candidates: make(map[int32]*genai.Candidate)}
+		for chunk, err := range streamer.GenerateContentStream(ctx, model, parts, contentConfig(opts)) {
+			if err != nil {
+				yield(domain.StreamEvent{}, classifyClientError(err))
+				return
+			}
+			for _, ev := range acc.add(chunk) {
+				if !yield(ev, nil) {
+					return
+				}
+			}
+		}
+
+		seed := domain.DereferenceSeed(opts.Seed)
+		out, err := c.ParseToResponse(&gemini.Response{RawResponse: acc.response()}, seed)
+		if err != nil {
+			yield(domain.StreamEvent{}, err)
+			return
+		}
+		resp := newImageResponse(out, model)
+		resp.Attempts = []domain.GenerationAttempt{{Number: 1, Seed: seed}}
+		yield(domain.StreamEvent{Kind: domain.StreamEventFinal, Response: resp}, nil)
+	}
+}
+
+// streamAccumulator はストリーミングのチャンクを1つのレスポンスに結合します。
+type streamAccumulator struct {
+	candidates     map[int32]*genai.Candidate
+	usage          *genai.GenerateContentResponseUsageMetadata
+	modelVersion   string
+	promptFeedback *genai.GenerateContentResponsePromptFeedback
+}
+
+// add はチャンクを結合し、そのチャンクに含まれる途中経過をイベントとして返します。
+func (a *streamAccumulator) add(chunk *genai.GenerateContentResponse) []domain.StreamEvent {
+	if chunk == nil {
+		return nil
+	}
+	if chunk.UsageMetadata != nil {
+		a.usage = chunk.UsageMetadata
+	}
+	if chunk.ModelVersion != "" {
+		a.modelVersion = chunk.ModelVersion
+	}
+	if chunk.PromptFeedback != nil {
+		a.promptFeedback = chunk.PromptFeedback
+	}
+
+	var events []domain.StreamEvent
+	for _, cand := range chunk.Candidates {
+		if cand == nil {
+			continue
+		}
+		merged, ok := a.candidates[cand.Index]
+		if !ok {
+			merged = &genai.Candidate{Index: cand.Index}
+			a.candidates[cand.Index] = merged
+		}
+		if cand.FinishReason != "" {
+			merged.FinishReason = cand.FinishReason
+			merged.FinishMessage = cand.FinishMessage
+		}
+		if len(cand.SafetyRatings) > 0 {
+			merged.SafetyRatings = cand.SafetyRatings
+		}
+		if cand.Content == nil {
+			continue
+		}
+		if merged.Content == nil {
+			merged.Content = &genai.Content{Role: cand.Content.Role}
+		}
+
+		for _, part := range cand.Content.Parts {
+			if part == nil {
+				continue
+			}
+			switch {
+			case part.InlineData != nil:
+				events = append(events, domain.StreamEvent{
+					Kind: domain.StreamEventImage,
+					Image: &domain.GeneratedImage{
+						Data:           part.InlineData.Data,
+						MimeType:       part.InlineData.MIMEType,
+						CandidateIndex: int(cand.Index),
+					},
+				})
+			case part.Text != "" && part.Thought:
+				events = append(events, domain.StreamEvent{Kind: domain.StreamEventThought, Text: part.Text})
+			case part.Text != "":
+				events = append(events, domain.StreamEvent{Kind: domain.StreamEventText, Text: part.Text})
+			}
+			// 思考中の途中画像はイベントとしてのみ通知し、最終結果には含めない
+			if part.InlineData != nil && part.Thought {
+				continue
+			}
+			appendStreamPart(merged.Content, part)
+		}
+	}
+	return events
+}
+
+// appendStreamPart はパーツを追加します。分割されて届くテキストは直前のテキストパーツに連結します。
+func appendStreamPart(content *genai.Content, part *genai.Part) {
+	if n := len(content.Parts); n > 0 && part.Text != "" && part.InlineData == nil {
+		last := content.Parts[n-1]
+		if last.Text != "" && last.InlineData == nil && last.Thought == part.Thought {
+			content.Parts[n-1] = &genai.Part{Text: last.Text + part.Text, Thought: last.Thought}
+			return
+		}
+	}
+	content.Parts = append(content.Parts, part)
+}
+
+// response は結合済みのレスポンスを返します。候補は Index 順に並びます。
+func (a *streamAccumulator) response() *genai.GenerateContentResponse {
+	resp := &genai.GenerateContentResponse{
+		UsageMetadata:  a.usage,
+		ModelVersion:   a.modelVersion,
+		PromptFeedback: a.promptFeedback,
+	}
+	for _, cand := range a.candidates {
+		resp.Candidates = append(resp.Candidates, cand)
+	}
+	sort.Slice(resp.Candidates, func(i, j int) bool {
+		return resp.Candidates[i].Index < resp.Candidates[j].Index
+	})
+	return resp
+}
+
+// GenerateMangaPanelStream は GenerateMangaPanel のストリーミング版です。
+// 思考テキスト・途中の画像を届いた順に返し、最後に検証済みの結果を StreamEventFinal で返します。
+// 途中経過を返す前に失敗した場合のみ、フォールバックチェーンの次のモデルを試します。
+func (g *GeminiGenerator) GenerateMangaPanelStream(ctx context.Context, req domain.ImageGenerationRequest) iter.Seq2[domain.StreamEvent, error] {
+	return func(yield func(domain.StreamEvent, error) bool) {
+		page := req.ToPageRequest()
+		parts, refs, opts, err := g.prepareRequest(ctx, page)
+		if err != nil {
+			yield(domain.StreamEvent{}, err)
+			return
+		}
+
+		streamer, ok := g.core.(StreamExecutor)
+		if !ok {
+			resp, err := g.execute(ctx, withModelOverride(page.Model, g.panelModels()), parts, opts)
+			if err != nil {
+				yield(domain.StreamEvent{}, err)
+				return
+			}
+			resp.UsedReferences = refs.used
+			resp.SkippedReferences = refs.skipped
+			yield(domain.StreamEvent{Kind: domain.StreamEventFinal, Response: resp}, nil)
+			return
+		}
+
+		models := withModelOverride(page.Model, g.panelModels())
+		for i, model := range models {
+			emitted := false
+			var failure error
+			for ev, err := range streamer.ExecuteRequestStream(ctx, model, parts, opts) {
+				if err != nil {
+					failure = err
+					break
+				}
+				if ev.Kind == domain.StreamEventFinal {
+					ev.Response.UsedReferences = refs.used
+					ev.Response.SkippedReferences = refs.skipped
+				}
+				emitted = true
+				if !yield(ev, nil) {
+					return
+				}
+			}
+			if failure == nil {
+				return
+			}
+
+			if emitted || i == len(models)-1 || !shouldFallback(failure) || ctx.Err() != nil {
+				yield(domain.StreamEvent{}, failure)
+				return
+			}
+			slog.WarnContext(ctx, "モデルでの生成に失敗したため、次のモデルで再試行します", "model", model, "next", models[i+1], "error", failure)
+		}
+	}
+}
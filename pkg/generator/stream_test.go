@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"context"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genai"
+)
+
+// streamingAIClient は指定したチャンクを順に返す StreamingModel のモックです。
+type streamingAIClient struct {
+	mockAIClient
+	chunks []*genai.GenerateContentResponse
+	err    error
+	models []string
+}
+
+func (s *streamingAIClient) GenerateContentStream(ctx context.Context, model string, parts []*genai.Part, config *genai.GenerateContentConfig) iter.Seq2[*genai.GenerateContentResponse, error] {
+	s.models = append(s.models, model)
+	return func(yield func(*genai.GenerateContentResponse, error) bool) {
+		for _, c := range s.chunks {
+			if !yield(c, nil) {
+				return
+			}
+		}
+		if s.err != nil {
+			yield(nil, s.err)
+		}
+	}
+}
+
+func chunk(finish genai.FinishReason, parts ...*genai.Part) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{
+		FinishReason: finish,
+		Content:      &genai.Content{Role: genai.RoleModel, Parts: parts},
+	}}}
+}
+
+func collectStream(t *testing.T, seq iter.Seq2[domain.StreamEvent, error]) ([]domain.StreamEvent, error) {
+	t.Helper()
+	var events []domain.StreamEvent
+	for ev, err := range seq {
+		if err != nil {
+			return events, err
+		}
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+func TestGeminiImageCore_ExecuteRequestStream(t *testing.T) {
+	ctx := context.Background()
+	newCore := func(t *testing.T, ai gemini.GenerativeModel) *GeminiImageCore {
+		core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+		require.NoError(t, err)
+		return core
+	}
+
+	t.Run("思考・途中の画像・最終結果を届いた順に返す", func(t *testing.T) {
+		ai := &streamingAIClient{chunks: []*genai.GenerateContentResponse{
+			chunk("", &genai.Part{Text: "planning the ", Thought: true}),
+			chunk("", &genai.Part{Text: "layout", Thought: true}),
+			chunk("", &genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("draft")}, Thought: true}),
+			chunk(genai.FinishReasonStop,
+				&genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: []byte("final")}},
+				&genai.Part{Text: "done"},
+			),
+		}}
+
+		events, err := collectStream(t, newCore(t, ai).ExecuteRequestStream(ctx, "model", nil, GenerateOptions{}))
+		require.NoError(t, err)
+		require.Len(t, events, 6)
+
+		assert.Equal(t, domain.StreamEventThought, events[0].Kind)
+		assert.Equal(t, domain.StreamEventImage, events[2].Kind)
+		assert.Equal(t, []byte("draft"), events[2].Image.Data)
+		assert.Equal(t, domain.StreamEventText, events[4].Kind)
+
+		final := events[5]
+		require.Equal(t, domain.StreamEventFinal, final.Kind)
+		assert.Equal(t, []byte("final"), final.Response.Data, "the interim draft must not become the final image")
+		assert.Len(t, final.Response.Images, 1)
+		assert.Equal(t, "done", final.Response.Text, "thought text must not leak into the final text")
+		assert.Equal(t, "model", final.Response.Model)
+	})
+
+	t.Run("最終結果にも FinishReason の検証を適用する", func(t *testing.T) {
+		ai := &streamingAIClient{chunks: []*genai.GenerateContentResponse{
+			chunk("", &genai.Part{Text: "thinking", Thought: true}),
+			chunk(genai.FinishReasonImageSafety),
+		}}
+
+		events, err := collectStream(t, newCore(t, ai).ExecuteRequestStream(ctx, "model", nil, GenerateOptions{}))
+		assert.ErrorIs(t, err, ErrSafetyBlocked)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("クライアントのエラーは分類して返す", func(t *testing.T) {
+		ai := &streamingAIClient{err: genai.APIError{Code: 429}}
+		_, err := collectStream(t, newCore(t, ai).ExecuteRequestStream(ctx, "model", nil, GenerateOptions{}))
+		assert.ErrorIs(t, err, ErrQuotaExceeded)
+	})
+
+	t.Run("ストリーミング非対応のクライアントでは最終結果のみを返す", func(t *testing.T) {
+		events, err := collectStream(t, newCore(t, &mockAIClient{}).ExecuteRequestStream(ctx, "model", nil, GenerateOptions{}))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, domain.StreamEventFinal, events[0].Kind)
+	})
+
+	t.Run("途中で読み取りを止められる", func(t *testing.T) {
+		ai := &streamingAIClient{chunks: []*genai.GenerateContentResponse{
+			chunk("", &genai.Part{Text: "a", Thought: true}),
+			chunk("", &genai.Part{Text: "b", Thought: true}),
+		}}
+		count := 0
+		for range newCore(t, ai).ExecuteRequestStream(ctx, "model", nil, GenerateOptions{}) {
+			count++
+			break
+		}
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestGeminiGenerator_GenerateMangaPanelStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("ストリーミング非対応の ImageExecutor では最終結果のみを返す", func(t *testing.T) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec)
+		require.NoError(t, err)
+
+		events, err := collectStream(t, g.GenerateMangaPanelStream(ctx, domain.ImageGenerationRequest{
+			Prompt: "panel",
+			Image:  domain.ImageURI{ReferenceURL: "gs://bucket/ref.png"},
+		}))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Len(t, events[0].Response.UsedReferences, 1)
+	})
+
+	t.Run("途中経過を返す前の失敗では次のモデルに切り替える", func(t *testing.T) {
+		ai := &streamingAIClient{err: genai.APIError{Code: 503}}
+		core, err := NewGeminiImageCore(ai, &mockReader{}, &mockHTTPClient{}, nil, time.Hour)
+		require.NoError(t, err)
+		g, err := NewGeminiGenerator("model", "quality-model", core, WithPanelFallbackModels("backup"))
+		require.NoError(t, err)
+
+		_, err = collectStream(t, g.GenerateMangaPanelStream(ctx, domain.ImageGenerationRequest{Prompt: "panel"}))
+		assert.Error(t, err)
+		assert.Equal(t, []string{"model", "backup"}, ai.models)
+	})
+
+	t.Run("プロンプトが空の場合はエラー", func(t *testing.T) {
+		g, err := NewGeminiGenerator("model", "quality-model", &mockExecutor{})
+		require.NoError(t, err)
+		_, err = collectStream(t, g.GenerateMangaPanelStream(ctx, domain.ImageGenerationRequest{}))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// StylePreset は「少年誌スクリーントーン」「4コマ」のような画風の設定一式です。
+// リクエストの Style で名前を指定すると、リクエスト側の設定とマージして適用されます。
+type StylePreset struct {
+	Name           string   `json:"name" yaml:"name"`
+	SystemPrompt   string   `json:"system_prompt,omitempty" yaml:"system_prompt,omitempty"`
+	NegativePrompt string   `json:"negative_prompt,omitempty" yaml:"negative_prompt,omitempty"`
+	AspectRatio    string   `json:"aspect_ratio,omitempty" yaml:"aspect_ratio,omitempty"`
+	ImageSize      string   `json:"image_size,omitempty" yaml:"image_size,omitempty"`
+	References     []string `json:"references,omitempty" yaml:"references,omitempty"` // 画風の参照画像 URL
+}
+
+// StyleLibrary は名前付きの StylePreset を保持します。
+// 複数の goroutine から同時に使用できます。
+type StyleLibrary struct {
+	mu      sync.RWMutex
+	presets map[string]StylePreset
+}
+
+// NewStyleLibrary は presets を登録した StyleLibrary を作成します。
+func NewStyleLibrary(presets ...StylePreset) (*StyleLibrary, error) {
+	l := &StyleLibrary{presets: make(map[string]StylePreset)}
+	for _, p := range presets {
+		if err := l.Register(p); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// ParseStyleLibrary は YAML または JSON のプリセット一覧から StyleLibrary を作成します。
+// JSON は YAML のサブセットとして解釈されます。
+// 誤記による設定漏れを防ぐため、未知のキーと重複したプリセット名はエラーになります。
+func ParseStyleLibrary(data []byte) (*StyleLibrary, error) {
+	var presets []StylePreset
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&presets); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse style presets: %w", err)
+	}
+
+	seen := make(map[string]bool, len(presets))
+	for _, p := range presets {
+		if seen[p.Name] {
+			return nil, fmt.Errorf("duplicate style preset name: %s", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return NewStyleLibrary(presets...)
+}
+
+// LoadStyleLibrary はファイルからプリセット一覧を読み込みます。
+func LoadStyleLibrary(path string) (*StyleLibrary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read style presets: %w", err)
+	}
+	return ParseStyleLibrary(data)
+}
+
+// Register はプリセットを登録します。同じ名前のプリセットは上書きされます。
+func (l *StyleLibrary) Register(p StylePreset) error {
+	if p.Name == "" {
+		return fmt.Errorf("style preset name is required")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.presets[p.Name] = p
+	return nil
+}
+
+// Get は登録済みのプリセットを返します。
+func (l *StyleLibrary) Get(name string) (StylePreset, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	p, ok := l.presets[name]
+	return p, ok
+}
+
+// Names は登録済みのプリセット名を昇順で返します。
+func (l *StyleLibrary) Names() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	names := make([]string, 0, len(l.presets))
+	for name := range l.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply はプリセットをリクエストにマージします。
+// SystemPrompt と NegativePrompt はプリセットの後ろにリクエストの内容を追加し、
+// AspectRatio と ImageSize はリクエストで指定されていればそちらを優先します。
+// 参照画像はリクエストの参照画像の後ろに追加されます。
+func (p StylePreset) Apply(req domain.ImagePageRequest) domain.ImagePageRequest {
+	req.SystemPrompt = joinNonEmpty("\n\n", p.SystemPrompt, req.SystemPrompt)
+	req.NegativePrompt = joinNonEmpty(", ", p.NegativePrompt, req.NegativePrompt)
+	if req.AspectRatio == "" {
+		req.AspectRatio = p.AspectRatio
+	}
+	if req.ImageSize == "" {
+		req.ImageSize = p.ImageSize
+	}
+	if len(p.References) > 0 {
+		images := make([]domain.ImageURI, 0, len(req.Images)+len(p.References))
+		images = append(images, req.Images...)
+		for _, url := range p.References {
+			images = append(images, domain.ImageURI{ReferenceURL: url})
+		}
+		req.Images = images
+	}
+	return req
+}
+
+// WithStyleLibrary はリクエストの Style を解決するライブラリを設定します。
+func WithStyleLibrary(l *StyleLibrary) GeneratorOption {
+	return func(g *GeminiGenerator) {
+		g.styles = l
+	}
+}
+
+// applyStyle はリクエストの Style で指定されたプリセットをマージしたリクエストを返します。
+func (g *GeminiGenerator) applyStyle(req domain.ImagePageRequest) (domain.ImagePageRequest, error) {
+	if req.Style == "" {
+		return req, nil
+	}
+	if g.styles == nil {
+		return req, fmt.Errorf("%w: Style requires a StyleLibrary", ErrMissingDependency)
+	}
+	preset, ok := g.styles.Get(req.Style)
+	if !ok {
+		return req, fmt.Errorf("unknown style preset %q", req.Style)
+	}
+	return preset.Apply(req), nil
+}
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testStylePresetsYAML = `
+- name: shonen-screentone
+  system_prompt: Black and white manga with screentone shading.
+  negative_prompt: color, photorealistic
+  aspect_ratio: "3:4"
+  image_size: 2K
+  references:
+    - gs://styles/screentone.png
+- name: 4-koma
+  system_prompt: Four-panel vertical comic strip.
+  aspect_ratio: "1:4"
+`
+
+func TestParseStyleLibrary(t *testing.T) {
+	t.Run("YAML を読み込める", func(t *testing.T) {
+		l, err := ParseStyleLibrary([]byte(testStylePresetsYAML))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"4-koma", "shonen-screentone"}, l.Names())
+
+		p, ok := l.Get("shonen-screentone")
+		require.True(t, ok)
+		assert.Equal(t, "3:4", p.AspectRatio)
+		assert.Equal(t, []string{"gs://styles/screentone.png"}, p.References)
+	})
+
+	t.Run("JSON を読み込める", func(t *testing.T) {
+		l, err := ParseStyleLibrary([]byte(`[{"name": "full-color-webtoon", "aspect_ratio": "9:16", "image_size": "2K"}]`))
+		require.NoError(t, err)
+		p, ok := l.Get("full-color-webtoon")
+		require.True(t, ok)
+		assert.Equal(t, "9:16", p.AspectRatio)
+	})
+
+	t.Run("ファイルから読み込める", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "styles.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(testStylePresetsYAML), 0o600))
+		l, err := LoadStyleLibrary(path)
+		require.NoError(t, err)
+		assert.Len(t, l.Names(), 2)
+	})
+
+	t.Run("未知のキーはエラー", func(t *testing.T) {
+		_, err := ParseStyleLibrary([]byte("- name: typo\n  negative_promt: color\n"))
+		assert.ErrorContains(t, err, "negative_promt")
+	})
+
+	t.Run("プリセット名の重複はエラー", func(t *testing.T) {
+		_, err := ParseStyleLibrary([]byte(`[{"name": "4-koma"}, {"name": "4-koma", "aspect_ratio": "1:1"}]`))
+		assert.ErrorContains(t, err, "duplicate")
+	})
+
+	t.Run("空のファイルは空のライブラリになる", func(t *testing.T) {
+		l, err := ParseStyleLibrary(nil)
+		require.NoError(t, err)
+		assert.Empty(t, l.Names())
+	})
+
+	t.Run("名前のないプリセットはエラー", func(t *testing.T) {
+		_, err := ParseStyleLibrary([]byte(`[{"aspect_ratio": "1:1"}]`))
+		assert.Error(t, err)
+	})
+}
+
+func TestStylePreset_Apply(t *testing.T) {
+	preset := StylePreset{
+		Name:           "shonen-screentone",
+		SystemPrompt:   "Black and white manga.",
+		NegativePrompt: "color",
+		AspectRatio:    "3:4",
+		ImageSize:      "2K",
+		References:     []string{"gs://styles/screentone.png"},
+	}
+
+	t.Run("リクエストが空の項目はプリセットで補う", func(t *testing.T) {
+		req := preset.Apply(domain.ImagePageRequest{Prompt: "panel"})
+		assert.Equal(t, "Black and white manga.", req.SystemPrompt)
+		assert.Equal(t, "color", req.NegativePrompt)
+		assert.Equal(t, "3:4", req.AspectRatio)
+		assert.Equal(t, "2K", req.ImageSize)
+		assert.Equal(t, []domain.ImageURI{{ReferenceURL: "gs://styles/screentone.png"}}, req.Images)
+	})
+
+	t.Run("リクエストの指定はプリセットに追加または優先される", func(t *testing.T) {
+		req := preset.Apply(domain.ImagePageRequest{
+			Prompt:         "panel",
+			SystemPrompt:   "Use heavy speed lines.",
+			NegativePrompt: "text",
+			AspectRatio:    "16:9",
+			Images:         []domain.ImageURI{{ReferenceURL: "gs://bucket/hero.png"}},
+		})
+		assert.Equal(t, "Black and white manga.\n\nUse heavy speed lines.", req.SystemPrompt)
+		assert.Equal(t, "color, text", req.NegativePrompt)
+		assert.Equal(t, "16:9", req.AspectRatio)
+		assert.Equal(t, "2K", req.ImageSize)
+		require.Len(t, req.Images, 2)
+		assert.Equal(t, "gs://bucket/hero.png", req.Images[0].ReferenceURL)
+	})
+}
+
+func TestGeminiGenerator_Style(t *testing.T) {
+	ctx := context.Background()
+	library, err := ParseStyleLibrary([]byte(testStylePresetsYAML))
+	require.NoError(t, err)
+
+	t.Run("プリセットが送信内容に反映される", func(t *testing.T) {
+		exec := &mockExecutor{}
+		g, err := NewGeminiGenerator("model", "quality-model", exec, WithStyleLibrary(library))
+		require.NoError(t, err)
+
+		resp, err := g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "hero lands", Style: "shonen-screentone"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "3:4", exec.lastOpts.AspectRatio)
+		assert.Equal(t, "2K", exec.lastOpts.ImageSize)
+		assert.Equal(t, "Black and white manga with screentone shading.", exec.lastOpts.SystemPrompt)
+		assert.Contains(t, exec.lastParts[len(exec.lastParts)-1].Text, "color, photorealistic")
+		assert.Len(t, resp.UsedReferences, 1)
+	})
+
+	t.Run("未登録のスタイルはエラー", func(t *testing.T) {
+		g, err := NewGeminiGenerator("model", "quality-model", &mockExecutor{}, WithStyleLibrary(library))
+		require.NoError(t, err)
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "panel", Style: "noir"})
+		assert.Error(t, err)
+	})
+
+	t.Run("ライブラリが未設定の場合はエラー", func(t *testing.T) {
+		g, err := NewGeminiGenerator("model", "quality-model", &mockExecutor{})
+		require.NoError(t, err)
+		_, err = g.GenerateMangaPanel(ctx, domain.ImageGenerationRequest{Prompt: "panel", Style: "4-koma"})
+		assert.ErrorIs(t, err, ErrMissingDependency)
+	})
+}
@@ -1,15 +1,67 @@
 package generator
 
+import (
+	"github.com/shouni/gemini-image-kit/pkg/domain"
+	"github.com/shouni/go-gemini-client/pkg/gemini"
+	"google.golang.org/genai"
+)
+
 const (
-	UseImageCompression     = true
-	ImageCompressionQuality = 75
-	cacheKeyFileAPIURI      = "fileapi_uri:"
-	cacheKeyFileAPIName     = "fileapi_name:"
+	cacheKeyFileAPIURI  = "fileapi_uri:"
+	cacheKeyFileAPIName = "fileapi_name:"
 )
 
+// GenerateOptions は1回の生成リクエストのオプションです。
+// gemini.GenerateOptions の項目に加えて、go-gemini-client が送信しない TopK と ResponseMIMEType を保持します。
+type GenerateOptions struct {
+	SystemPrompt     string
+	Temperature      *float32
+	TopP             *float32
+	TopK             *float32
+	CandidateCount   *int32
+	ResponseMIMEType string
+	AspectRatio      string
+	ImageSize        string
+	Seed             *int64
+	SafetySettings   []*genai.SafetySetting
+}
+
+// clientOptions は go-gemini-client に渡すオプションに変換します。TopK と ResponseMIMEType は含まれません。
+func (o GenerateOptions) clientOptions() gemini.GenerateOptions {
+	return gemini.GenerateOptions{
+		SystemPrompt:   o.SystemPrompt,
+		Temperature:    o.Temperature,
+		TopP:           o.TopP,
+		CandidateCount: o.CandidateCount,
+		AspectRatio:    o.AspectRatio,
+		ImageSize:      o.ImageSize,
+		Seed:           o.Seed,
+		SafetySettings: o.SafetySettings,
+	}
+}
+
+// fromClientOptions は go-gemini-client のオプションを GenerateOptions に変換します。
+func fromClientOptions(o gemini.GenerateOptions) GenerateOptions {
+	return GenerateOptions{
+		SystemPrompt:   o.SystemPrompt,
+		Temperature:    o.Temperature,
+		TopP:           o.TopP,
+		CandidateCount: o.CandidateCount,
+		AspectRatio:    o.AspectRatio,
+		ImageSize:      o.ImageSize,
+		Seed:           o.Seed,
+		SafetySettings: o.SafetySettings,
+	}
+}
+
 // ImageOutput は Core の内部解析結果
 type ImageOutput struct {
-	Data     []byte
-	MimeType string
-	UsedSeed int64
+	Data          []byte
+	MimeType      string
+	UsedSeed      int64
+	Images        []domain.GeneratedImage
+	Text          string
+	SafetyRatings []domain.SafetyRating
+	Usage         *domain.TokenUsage
+	ModelVersion  string
 }
@@ -0,0 +1,108 @@
+package imgutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+const (
+	maxBudgetQuality = 95
+	budgetScaleStep  = 0.75
+	minBudgetSide    = 64
+)
+
+// ErrBudgetTooSmall は最小品質・最小サイズまで落としても上限に収まらない場合のエラーです。
+var ErrBudgetTooSmall = errors.New("image cannot fit within byte budget")
+
+// CompressToBudget は画像データを maxBytes 以下の JPEG に圧縮します。
+// まず品質を二分探索し、最低品質でも収まらない場合は解像度を段階的に下げて再探索します。
+// 透過を含む画像 (線画やマスクなど) は JPEG にせず、PNG のまま解像度のみを下げて収めます。
+// 元データが既に上限以下の場合はそのまま返します。
+func CompressToBudget(data []byte, maxBytes int) ([]byte, error) {
+	if maxBytes <= 0 || len(data) <= maxBytes {
+		return data, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if !isOpaque(img) {
+		return encodePNGToBudget(img, maxBytes)
+	}
+	return encodeToBudget(img, maxBudgetQuality, maxBytes)
+}
+
+// encodePNGToBudget は透過を維持したまま、maxBytes に収まるまで解像度を段階的に下げて PNG エンコードします。
+func encodePNGToBudget(img image.Image, maxBytes int) ([]byte, error) {
+	for {
+		out, err := encodePNG(img)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) <= maxBytes {
+			return out, nil
+		}
+
+		img, err = shrinkForBudget(img, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// shrinkForBudget は画像を budgetScaleStep 倍に縮小します。最小サイズを下回る場合は ErrBudgetTooSmall を返します。
+func shrinkForBudget(img image.Image, maxBytes int) (image.Image, error) {
+	b := img.Bounds()
+	w := int(float64(b.Dx()) * budgetScaleStep)
+	h := int(float64(b.Dy()) * budgetScaleStep)
+	if w < minBudgetSide || h < minBudgetSide {
+		return nil, fmt.Errorf("%w: %d bytes", ErrBudgetTooSmall, maxBytes)
+	}
+	return Resize(img, w, h), nil
+}
+
+// encodeToBudget は maxQuality を上限に、maxBytes に収まる最も高い品質で JPEG エンコードします。
+func encodeToBudget(img image.Image, maxQuality, maxBytes int) ([]byte, error) {
+	if maxQuality <= 0 || maxQuality > 100 {
+		maxQuality = maxBudgetQuality
+	}
+
+	for {
+		out, err := searchJPEGQuality(img, minJPEGQuality, maxQuality, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		if out != nil {
+			return out, nil
+		}
+
+		// 最低品質でも収まらないため縮小して再試行
+		if img, err = shrinkForBudget(img, maxBytes); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// searchJPEGQuality は [lo, hi] の品質を二分探索し、maxBytes 以下となる最大品質の結果を返します。
+// どの品質でも収まらない場合は nil を返します。
+func searchJPEGQuality(img image.Image, lo, hi, maxBytes int) ([]byte, error) {
+	var best []byte
+	for lo <= hi {
+		q := (lo + hi) / 2
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: q}); err != nil {
+			return nil, err
+		}
+		if buf.Len() <= maxBytes {
+			best = buf.Bytes()
+			lo = q + 1
+		} else {
+			hi = q - 1
+		}
+	}
+	return best, nil
+}
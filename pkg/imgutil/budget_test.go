@@ -0,0 +1,111 @@
+package imgutil
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+)
+
+// 圧縮しにくいノイズ画像を作成するヘルパー
+func createNoisePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	r := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.RGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("failed to encode noise image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressToBudget(t *testing.T) {
+	input := createNoisePNG(t, 256, 256)
+
+	t.Run("上限以下のデータはそのまま返すこと", func(t *testing.T) {
+		got, err := CompressToBudget(input, len(input))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Error("data should be returned unchanged")
+		}
+	})
+
+	t.Run("品質の調整で上限内に収めること", func(t *testing.T) {
+		budget := len(input) / 4
+		got, err := CompressToBudget(input, budget)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) > budget {
+			t.Errorf("output size %d exceeds budget %d", len(got), budget)
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(got))
+		if err != nil || format != "jpeg" {
+			t.Fatalf("expected jpeg output, got %s (err: %v)", format, err)
+		}
+		if cfg.Width != 256 {
+			t.Errorf("resolution should be kept when quality alone is enough, got width %d", cfg.Width)
+		}
+	})
+
+	t.Run("最低品質でも収まらない場合は縮小して収めること", func(t *testing.T) {
+		budget := 6 * 1024
+		got, err := CompressToBudget(input, budget)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) > budget {
+			t.Errorf("output size %d exceeds budget %d", len(got), budget)
+		}
+		cfg, _, _ := image.DecodeConfig(bytes.NewReader(got))
+		if cfg.Width >= 256 {
+			t.Errorf("image should be downscaled, got width %d", cfg.Width)
+		}
+	})
+
+	t.Run("どうしても収まらない場合は ErrBudgetTooSmall を返すこと", func(t *testing.T) {
+		_, err := CompressToBudget(input, 100)
+		if !errors.Is(err, ErrBudgetTooSmall) {
+			t.Errorf("expected ErrBudgetTooSmall, got %v", err)
+		}
+	})
+	t.Run("透過を含む画像は PNG のまま縮小して収めること", func(t *testing.T) {
+		r := rand.New(rand.NewSource(2))
+		src := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+		for x := 0; x < 256; x++ {
+			for y := 0; y < 256; y++ {
+				src.Set(x, y, color.NRGBA{uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), 128})
+			}
+		}
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, src); err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+
+		budget := buf.Len() / 3
+		got, err := CompressToBudget(buf.Bytes(), budget)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) > budget {
+			t.Errorf("output size %d exceeds budget %d", len(got), budget)
+		}
+		img, format, err := image.Decode(bytes.NewReader(got))
+		if err != nil || format != "png" {
+			t.Fatalf("expected png output, got %s (err: %v)", format, err)
+		}
+		if isOpaque(img) {
+			t.Error("alpha channel should be preserved")
+		}
+	})
+}
@@ -2,12 +2,122 @@ package imgutil
 
 import (
 	"bytes"
+	"fmt"
 	"image"
 	_ "image/gif"
 	"image/jpeg"
-	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Format は画像フォーマットを表します。値は image.Decode が返すフォーマット名と一致します。
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatGIF  Format = "gif"
+	FormatWebP Format = "webp"
+	// FormatPassthrough は元のフォーマットを維持します。
+	// WebP などエンコーダのないフォーマットは、縮小が必要な場合のみ JPEG/PNG に変換されます。
+	FormatPassthrough Format = ""
+)
+
+const (
+	DefaultJPEGQuality = 75
+	minJPEGQuality     = 10
 )
 
+// CompressionPolicy は送信前の画像最適化の方針を定義します。
+type CompressionPolicy struct {
+	Enabled       bool   // false の場合は元データをそのまま使用
+	Format        Format // 出力フォーマット (JPEG / PNG / パススルー)。それ以外は ErrUnsupportedFormat
+	Quality       int    // JPEG 出力時の品質 (1-100)
+	MaxWidth      int    // 最大幅 (0 は無制限)
+	MaxHeight     int    // 最大高さ (0 は無制限)
+	MaxBytes      int    // 出力サイズの上限 (0 は無制限)。JPEG 出力時は品質と解像度を下げて収める
+	PreserveAlpha bool   // 透過を含む画像は JPEG にせず PNG のまま維持
+}
+
+// DefaultCompressionPolicy は JPEG 品質 75 で圧縮し、透過画像は PNG のまま維持するポリシーを返します。
+// 長辺が MaxInputDimension を超える画像は自動的に縮小されます。
+func DefaultCompressionPolicy() CompressionPolicy {
+	return CompressionPolicy{
+		Enabled:       true,
+		Format:        FormatJPEG,
+		Quality:       DefaultJPEGQuality,
+		MaxWidth:      MaxInputDimension,
+		MaxHeight:     MaxInputDimension,
+		PreserveAlpha: true,
+	}
+}
+
+// Validate はポリシーの出力フォーマットがエンコード可能かどうかを検証します。
+// 無効 (Enabled が false) のポリシーは常に有効です。
+func (p CompressionPolicy) Validate() error {
+	if !p.Enabled {
+		return nil
+	}
+	switch p.Format {
+	case FormatJPEG, FormatPNG, FormatPassthrough:
+		return nil
+	default:
+		return fmt.Errorf("%w: cannot encode %s, use FormatJPEG, FormatPNG or FormatPassthrough", ErrUnsupportedFormat, p.Format)
+	}
+}
+
+// Compress はポリシーに従って画像データを変換します。
+// 再エンコードする場合は JPEG の EXIF Orientation を画素に反映し、メタデータは出力に含まれません。
+// 変換が不要な場合は元のデータをそのまま返すため、メタデータの除去には Normalize を併用してください。
+func Compress(data []byte, p CompressionPolicy) ([]byte, error) {
+	if !p.Enabled {
+		return data, nil
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := DetectFormat(data); err != nil {
+		return nil, err
+	}
+
+	img, srcFormat, err := decodeOriented(data)
+	if err != nil {
+		return nil, err
+	}
+
+	srcSize := img.Bounds().Size()
+	img = FitWithin(img, p.MaxWidth, p.MaxHeight)
+	resized := img.Bounds().Size() != srcSize
+
+	target := p.Format
+	if target == FormatPassthrough {
+		// サイズ変更が不要なら元データをそのまま使う
+		if !resized && (p.MaxBytes <= 0 || len(data) <= p.MaxBytes) {
+			return data, nil
+		}
+		target = Format(srcFormat)
+	}
+	// GIF や WebP など再エンコードできないフォーマットは JPEG に変換する
+	if target != FormatPNG {
+		target = FormatJPEG
+	}
+	if target == FormatJPEG && p.PreserveAlpha && !isOpaque(img) {
+		target = FormatPNG
+	}
+
+	if target == FormatPNG {
+		return encodePNG(img)
+	}
+	if p.MaxBytes > 0 {
+		return encodeToBudget(img, jpegQuality(p.Quality), p.MaxBytes)
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: jpegQuality(p.Quality)}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 // CompressToJPEG は画像データ（PNG, GIF, JPEG等）をJPEG形式に圧縮します。
 // image.Decodeがサポートするフォーマットに対応しています。
 func CompressToJPEG(data []byte, quality int) ([]byte, error) {
@@ -22,3 +132,27 @@ func CompressToJPEG(data []byte, quality int) ([]byte, error) {
 	}
 	return buf.Bytes(), nil
 }
+
+// jpegQuality は範囲外の品質指定をデフォルト値に置き換えます。
+func jpegQuality(q int) int {
+	if q <= 0 || q > 100 {
+		return DefaultJPEGQuality
+	}
+	return q
+}
+
+// isOpaque は画像が完全に不透明かどうかを判定します。
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
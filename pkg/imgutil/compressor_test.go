@@ -2,6 +2,7 @@ package imgutil
 
 import (
 	"bytes"
+	"errors"
 	"image"
 	"image/color"
 	"image/jpeg"
@@ -77,3 +78,94 @@ func TestCompressToJPEG(t *testing.T) {
 		}
 	})
 }
+
+// 透過ピクセルを含む PNG を作成するヘルパー
+func createTransparentPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.NRGBA{0, 0, 0, uint8((x * 255) / w)})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("failed to encode transparent png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompress(t *testing.T) {
+	t.Run("無効化されている場合は元データをそのまま返すこと", func(t *testing.T) {
+		input := createDummyImageData(t, "png")
+		got, err := Compress(input, CompressionPolicy{Enabled: false})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Error("data should be returned unchanged")
+		}
+	})
+
+	t.Run("デフォルトポリシーでは不透明な画像をJPEGに変換すること", func(t *testing.T) {
+		got, err := Compress(createDummyImageData(t, "png"), DefaultCompressionPolicy())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, format, _ := image.DecodeConfig(bytes.NewReader(got)); format != "jpeg" {
+			t.Errorf("expected jpeg, got %s", format)
+		}
+	})
+
+	t.Run("PreserveAlpha では透過PNGをPNGのまま維持すること", func(t *testing.T) {
+		got, err := Compress(createTransparentPNG(t, 16, 16), DefaultCompressionPolicy())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		img, format, err := image.Decode(bytes.NewReader(got))
+		if err != nil || format != "png" {
+			t.Fatalf("expected png, got %s (err: %v)", format, err)
+		}
+		if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+			t.Errorf("alpha channel should be preserved, got alpha %d", a)
+		}
+	})
+
+	t.Run("最大サイズを超える画像はアスペクト比を保って縮小すること", func(t *testing.T) {
+		policy := DefaultCompressionPolicy()
+		policy.MaxWidth = 5
+		policy.MaxHeight = 5
+
+		got, err := Compress(createTransparentPNG(t, 20, 10), policy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(got))
+		if err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		if cfg.Width != 5 || cfg.Height != 2 {
+			t.Errorf("expected 5x2, got %dx%d", cfg.Width, cfg.Height)
+		}
+	})
+
+	t.Run("パススルーで変換不要な場合は元データを返すこと", func(t *testing.T) {
+		input := createDummyImageData(t, "png")
+		policy := CompressionPolicy{Enabled: true, Format: FormatPassthrough}
+		got, err := Compress(input, policy)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(got, input) {
+			t.Error("data should be passed through unchanged")
+		}
+	})
+	t.Run("エンコーダのないフォーマットを指定した場合はエラーを返すこと", func(t *testing.T) {
+		for _, format := range []Format{FormatWebP, FormatGIF} {
+			policy := CompressionPolicy{Enabled: true, Format: format}
+			if _, err := Compress(createDummyImageData(t, "png"), policy); !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("%s: expected ErrUnsupportedFormat, got %v", format, err)
+			}
+		}
+	})
+}
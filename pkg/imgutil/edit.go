@@ -0,0 +1,77 @@
+package imgutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// Insets は画像の上下左右に追加する余白 (px) です。
+type Insets struct {
+	Top, Right, Bottom, Left int
+}
+
+// IsZero は余白が指定されていない場合に true を返します。
+func (in Insets) IsZero() bool {
+	return in.Top <= 0 && in.Right <= 0 && in.Bottom <= 0 && in.Left <= 0
+}
+
+// Pad は画像の周囲に fill 色の余白を追加し、PNG として返します。
+// アウトペイント (画像外側の描き足し) の下準備に使用します。
+func Pad(data []byte, in Insets, fill color.Color) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	b := img.Bounds()
+	top, right, bottom, left := max(in.Top, 0), max(in.Right, 0), max(in.Bottom, 0), max(in.Left, 0)
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx()+left+right, b.Dy()+top+bottom))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(fill), image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(left, top, left+b.Dx(), top+b.Dy()), img, b.Min, draw.Over)
+
+	return encodePNG(dst)
+}
+
+// AlphaToMask は透過を含む画像を白黒の二値マスクに変換し、PNG として返します。
+// 透明な部分 (アルファ値が半分未満) を白 (編集対象)、それ以外を黒 (保持) とします。
+// 画像が完全に不透明な場合は、既に二値マスクであるとみなして元のデータと false を返します。
+func AlphaToMask(data []byte) ([]byte, bool, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, false, err
+	}
+	if isOpaque(img) {
+		return data, false, nil
+	}
+
+	b := img.Bounds()
+	mask := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			_, _, _, a := img.At(x, y).RGBA()
+			v := color.Black
+			if a < 0x8000 {
+				v = color.White
+			}
+			mask.Set(x-b.Min.X, y-b.Min.Y, v)
+		}
+	}
+
+	out, err := encodePNG(mask)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// encodePNG は画像を PNG にエンコードします。
+func encodePNG(img image.Image) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
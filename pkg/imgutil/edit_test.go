@@ -0,0 +1,65 @@
+package imgutil
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestPad(t *testing.T) {
+	input := createDummyImageData(t, "png") // 10x10 の赤い正方形
+
+	got, err := Pad(input, Insets{Top: 2, Right: 3, Bottom: 4, Left: 5}, color.White)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(got))
+	if err != nil || format != "png" {
+		t.Fatalf("expected png output, got %s (err: %v)", format, err)
+	}
+	if img.Bounds().Dx() != 18 || img.Bounds().Dy() != 16 {
+		t.Errorf("expected 18x16, got %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+	}
+	if r, g, b, _ := img.At(0, 0).RGBA(); r != 0xffff || g != 0xffff || b != 0xffff {
+		t.Errorf("padding should be filled with white")
+	}
+	if _, g, _, _ := img.At(5, 2).RGBA(); g != 0 {
+		t.Errorf("original image should be placed at the offset")
+	}
+}
+
+func TestAlphaToMask(t *testing.T) {
+	t.Run("透明部分を白、不透明部分を黒に変換すること", func(t *testing.T) {
+		got, converted, err := AlphaToMask(createTransparentPNG(t, 16, 4))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !converted {
+			t.Fatal("transparent image should be converted")
+		}
+		img, _, err := image.Decode(bytes.NewReader(got))
+		if err != nil {
+			t.Fatalf("failed to decode mask: %v", err)
+		}
+		// createTransparentPNG は左端ほど透明
+		if r, _, _, _ := img.At(0, 0).RGBA(); r != 0xffff {
+			t.Error("transparent pixel should become white")
+		}
+		if r, _, _, _ := img.At(15, 0).RGBA(); r != 0 {
+			t.Error("opaque pixel should become black")
+		}
+	})
+
+	t.Run("不透明な画像はそのまま返すこと", func(t *testing.T) {
+		input := createDummyImageData(t, "png")
+		got, converted, err := AlphaToMask(input)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if converted || !bytes.Equal(got, input) {
+			t.Error("opaque image should be returned unchanged")
+		}
+	})
+}
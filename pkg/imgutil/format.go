@@ -0,0 +1,62 @@
+package imgutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	FormatAVIF Format = "avif"
+	FormatHEIC Format = "heic"
+)
+
+// ErrUnsupportedFormat は画像として認識できない、またはデコードできないフォーマットのエラーです。
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// MimeType はフォーマットに対応する MIME タイプを返します。
+func (f Format) MimeType() string {
+	switch f {
+	case FormatJPEG:
+		return "image/jpeg"
+	case FormatPNG:
+		return "image/png"
+	case FormatGIF:
+		return "image/gif"
+	case FormatWebP:
+		return "image/webp"
+	case FormatAVIF:
+		return "image/avif"
+	case FormatHEIC:
+		return "image/heic"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// DetectFormat は先頭のマジックバイトから画像フォーマットを判定します。
+// AVIF と HEIC/HEIF はデコーダがないため、フォーマットと併せて ErrUnsupportedFormat を返します。
+func DetectFormat(data []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG, nil
+	case bytes.HasPrefix(data, pngSignature):
+		return FormatPNG, nil
+	case bytes.HasPrefix(data, []byte("GIF87a")), bytes.HasPrefix(data, []byte("GIF89a")):
+		return FormatGIF, nil
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP, nil
+	}
+
+	// ISO BMFF (ftyp ボックス) のブランドで AVIF / HEIC を判定する
+	if len(data) >= 12 && string(data[4:8]) == "ftyp" {
+		switch string(data[8:12]) {
+		case "avif", "avis":
+			return FormatAVIF, fmt.Errorf("%w: AVIF is not supported, convert it to PNG or JPEG", ErrUnsupportedFormat)
+		case "heic", "heix", "hevc", "hevx", "heim", "heis", "mif1", "msf1":
+			return FormatHEIC, fmt.Errorf("%w: HEIC/HEIF is not supported, convert it to PNG or JPEG", ErrUnsupportedFormat)
+		}
+	}
+
+	return "", ErrUnsupportedFormat
+}
@@ -0,0 +1,72 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"testing"
+)
+
+// 1x1 のロスレス WebP 画像
+const tinyWebP = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func decodeTinyWebP(t *testing.T) []byte {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(tinyWebP)
+	if err != nil {
+		t.Fatalf("failed to decode fixture: %v", err)
+	}
+	return data
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    Format
+		wantErr bool
+	}{
+		{"JPEG", createDummyImageData(t, "jpeg"), FormatJPEG, false},
+		{"PNG", createDummyImageData(t, "png"), FormatPNG, false},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00"), FormatGIF, false},
+		{"WebP", decodeTinyWebP(t), FormatWebP, false},
+		{"AVIF", []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00"), FormatAVIF, true},
+		{"HEIC", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), FormatHEIC, true},
+		{"画像以外", []byte("<html></html>"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DetectFormat(tt.data)
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if tt.wantErr && !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFormat_MimeType(t *testing.T) {
+	if got := FormatWebP.MimeType(); got != "image/webp" {
+		t.Errorf("got %s, want image/webp", got)
+	}
+	if got := FormatJPEG.MimeType(); got != "image/jpeg" {
+		t.Errorf("got %s, want image/jpeg", got)
+	}
+}
+
+func TestCompress_WebP(t *testing.T) {
+	got, err := Compress(decodeTinyWebP(t), DefaultCompressionPolicy())
+	if err != nil {
+		t.Fatalf("WebP should be decodable: %v", err)
+	}
+	if _, format, err := image.DecodeConfig(bytes.NewReader(got)); err != nil || format == "webp" {
+		t.Errorf("WebP should be re-encoded, got %s (err: %v)", format, err)
+	}
+}
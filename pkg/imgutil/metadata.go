@@ -0,0 +1,401 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+)
+
+const (
+	normalizedJPEGQuality = 95
+	exifOrientationTag    = 0x0112
+)
+
+var (
+	jpegSOI      = []byte{0xFF, 0xD8}
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader   = []byte("Exif\x00\x00")
+	riffHeader   = []byte("RIFF")
+	webpFourCC   = []byte("WEBP")
+	gif87a       = []byte("GIF87a")
+	gif89a       = []byte("GIF89a")
+)
+
+// ErrMalformedImage は画像のバイナリ構造が壊れている場合のエラーです。
+var ErrMalformedImage = errors.New("malformed image data")
+
+// Normalize は EXIF の Orientation を画素に反映し、位置情報などの個人情報を含むメタデータを除去します。
+// JPEG は回転が不要であれば再エンコードせずにメタデータのセグメントのみを取り除きます。
+// PNG はテキスト・EXIF・タイムスタンプのチャンクを取り除きます。
+// WebP は EXIF・XMP のチャンクを、GIF はコメントとアプリケーション拡張 (ループ回数の指定を除く) を取り除きます。
+// 画像として認識できないデータは元のデータをそのまま返します。
+func Normalize(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, jpegSOI):
+		return normalizeJPEG(data)
+	case bytes.HasPrefix(data, pngSignature):
+		return stripPNGMetadata(data)
+	case len(data) >= 12 && bytes.Equal(data[:4], riffHeader) && bytes.Equal(data[8:12], webpFourCC):
+		return stripWebPMetadata(data)
+	case bytes.HasPrefix(data, gif87a) || bytes.HasPrefix(data, gif89a):
+		return stripGIFMetadata(data)
+	default:
+		return data, nil
+	}
+}
+
+// normalizeJPEG は JPEG の向きを補正し、メタデータを除去します。
+func normalizeJPEG(data []byte) ([]byte, error) {
+	orientation, err := jpegOrientation(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if orientation <= 1 || orientation > 8 {
+		return stripJPEGMetadata(data)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	// 標準のエンコーダはメタデータを書き出さないため、再エンコードで同時に除去される
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, applyOrientation(img, orientation), &jpeg.Options{Quality: normalizedJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeOriented は画像をデコードし、JPEG の場合は EXIF の Orientation を画素に反映します。
+func decodeOriented(data []byte) (image.Image, string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	if format == string(FormatJPEG) {
+		if orientation, err := jpegOrientation(data); err == nil && orientation > 1 && orientation <= 8 {
+			img = applyOrientation(img, orientation)
+		}
+	}
+	return img, format, nil
+}
+
+// walkJPEGSegments は SOS (スキャン開始) までのセグメントを順に fn へ渡し、画像データ本体の開始位置を返します。
+func walkJPEGSegments(data []byte, fn func(marker byte, segment []byte)) (int, error) {
+	pos := len(jpegSOI)
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0, ErrMalformedImage
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// フィルバイト
+			pos++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 {
+			return pos, nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return 0, ErrMalformedImage
+		}
+		fn(marker, data[pos:end])
+		pos = end
+	}
+	return 0, ErrMalformedImage
+}
+
+// isMetadataSegment は除去対象のセグメントかどうかを判定します。
+// APP1 (EXIF/XMP)、APP12、APP13 (IPTC)、COM (コメント) が対象で、
+// APP0 (JFIF)、APP2 (ICC プロファイル)、APP14 (Adobe) は表示に影響するため残します。
+func isMetadataSegment(marker byte) bool {
+	switch marker {
+	case 0xE1, 0xEC, 0xED, 0xFE:
+		return true
+	default:
+		return false
+	}
+}
+
+// stripJPEGMetadata は再エンコードせずにメタデータのセグメントを取り除きます。
+func stripJPEGMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(jpegSOI)
+
+	scanStart, err := walkJPEGSegments(data, func(marker byte, segment []byte) {
+		if !isMetadataSegment(marker) {
+			out.Write(segment)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	out.Write(data[scanStart:])
+	return out.Bytes(), nil
+}
+
+// jpegOrientation は APP1 の EXIF から Orientation タグを読み取ります。
+// タグが存在しない場合は 1 (補正不要) を返します。
+func jpegOrientation(data []byte) (int, error) {
+	orientation := 1
+	_, err := walkJPEGSegments(data, func(marker byte, segment []byte) {
+		if marker != 0xE1 || len(segment) < 4 {
+			return
+		}
+		payload := segment[4:]
+		if !bytes.HasPrefix(payload, exifHeader) {
+			return
+		}
+		if o, ok := parseTIFFOrientation(payload[len(exifHeader):]); ok {
+			orientation = o
+		}
+	})
+	return orientation, err
+}
+
+// parseTIFFOrientation は TIFF 形式の IFD0 から Orientation を探します。
+func parseTIFFOrientation(tiff []byte) (int, bool) {
+	if len(tiff) < 8 {
+		return 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0, false
+	}
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:entry+2]) == exifOrientationTag {
+			return int(order.Uint16(tiff[entry+8 : entry+10])), true
+		}
+	}
+	return 0, false
+}
+
+// applyOrientation は EXIF の Orientation (2-8) に従って画像を反転・回転します。
+func applyOrientation(img image.Image, orientation int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 左右反転
+				dx, dy = w-1-x, y
+			case 3: // 180度回転
+				dx, dy = w-1-x, h-1-y
+			case 4: // 上下反転
+				dx, dy = x, h-1-y
+			case 5: // 転置
+				dx, dy = y, x
+			case 6: // 時計回りに90度回転
+				dx, dy = h-1-y, x
+			case 7: // 反転置
+				dx, dy = h-1-y, w-1-x
+			case 8: // 反時計回りに90度回転
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// stripPNGMetadata は PNG から個人情報を含みうる補助チャンクを取り除きます。
+func stripPNGMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+
+	pos := len(pngSignature)
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		chunkType := string(data[pos+4 : pos+8])
+		end := pos + 12 + length // 長さ(4) + 種別(4) + データ + CRC(4)
+		if length < 0 || end > len(data) {
+			return nil, ErrMalformedImage
+		}
+
+		switch chunkType {
+		case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+			// メタデータチャンクは書き出さない
+		default:
+			out.Write(data[pos:end])
+		}
+
+		pos = end
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out.Bytes(), nil
+}
+
+const (
+	webpFlagEXIF = 0x08 // VP8X フラグの EXIF ビット
+	webpFlagXMP  = 0x04 // VP8X フラグの XMP ビット
+)
+
+// stripWebPMetadata は WebP (RIFF コンテナ) から EXIF と XMP のチャンクを取り除きます。
+// VP8X チャンクの対応するフラグも下ろし、RIFF のサイズを書き直します。
+func stripWebPMetadata(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, ErrMalformedImage
+		}
+		fourCC := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2 // チャンクは偶数バイトに揃えられる
+		if pos+8+size > len(data) {
+			return nil, ErrMalformedImage
+		}
+		end = min(end, len(data))
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+			// メタデータチャンクは書き出さない
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if size > 0 {
+				chunk[8] &^= webpFlagEXIF | webpFlagXMP
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:end])
+		}
+		pos = end
+	}
+
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	return result, nil
+}
+
+// stripGIFMetadata は GIF からコメント拡張とアプリケーション拡張 (XMP など) を取り除きます。
+// アニメーションのループ回数を指定する NETSCAPE2.0 / ANIMEXTS1.0 拡張は残します。
+func stripGIFMetadata(data []byte) ([]byte, error) {
+	const headerLen = 13 // シグネチャ(6) + 論理画面記述子(7)
+	if len(data) < headerLen {
+		return nil, ErrMalformedImage
+	}
+	pos := headerLen
+	if flags := data[10]; flags&0x80 != 0 {
+		pos += 3 << ((flags & 0x07) + 1) // グローバルカラーテーブル
+	}
+	if pos > len(data) {
+		return nil, ErrMalformedImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:pos])
+	for pos < len(data) {
+		start := pos
+		switch data[pos] {
+		case 0x3B: // トレーラー
+			out.WriteByte(0x3B)
+			return out.Bytes(), nil
+		case 0x21: // 拡張ブロック
+			if pos+2 > len(data) {
+				return nil, ErrMalformedImage
+			}
+			label := data[pos+1]
+			end, err := skipGIFSubBlocks(data, pos+2)
+			if err != nil {
+				return nil, err
+			}
+			if !isGIFMetadataExtension(label, data[pos+2:end]) {
+				out.Write(data[start:end])
+			}
+			pos = end
+		case 0x2C: // 画像記述子
+			if pos+10 > len(data) {
+				return nil, ErrMalformedImage
+			}
+			pos += 10
+			if flags := data[pos-1]; flags&0x80 != 0 {
+				pos += 3 << ((flags & 0x07) + 1) // ローカルカラーテーブル
+			}
+			pos++ // LZW 最小コードサイズ
+			if pos > len(data) {
+				return nil, ErrMalformedImage
+			}
+			end, err := skipGIFSubBlocks(data, pos)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(data[start:end])
+			pos = end
+		default:
+			return nil, ErrMalformedImage
+		}
+	}
+	return nil, ErrMalformedImage
+}
+
+// skipGIFSubBlocks は pos から始まるデータサブブロックの列を読み飛ばし、終端ブロックの直後の位置を返します。
+func skipGIFSubBlocks(data []byte, pos int) (int, error) {
+	for {
+		if pos >= len(data) {
+			return 0, ErrMalformedImage
+		}
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos, nil
+		}
+		pos += size
+	}
+}
+
+// isGIFMetadataExtension は拡張ブロックがメタデータ (コメント、ループ回数以外のアプリケーション拡張) かどうかを判定します。
+// blocks は拡張ラベルの直後から始まるサブブロックの列です。
+func isGIFMetadataExtension(label byte, blocks []byte) bool {
+	switch label {
+	case 0xFE:
+		return true
+	case 0xFF:
+		if len(blocks) < 12 || blocks[0] != 11 {
+			return true
+		}
+		id := string(blocks[1:12])
+		return id != "NETSCAPE2.0" && id != "ANIMEXTS1.0"
+	default:
+		return false
+	}
+}
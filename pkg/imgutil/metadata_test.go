@@ -0,0 +1,251 @@
+package imgutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// EXIF (Orientation と GPS 風のダミー文字列) を含む APP1 セグメントを作成するヘルパー
+func exifSegment(orientation uint16) []byte {
+	tiff := new(bytes.Buffer)
+	tiff.WriteString("MM")
+	binary.Write(tiff, binary.BigEndian, uint16(42))
+	binary.Write(tiff, binary.BigEndian, uint32(8)) // IFD0 のオフセット
+	binary.Write(tiff, binary.BigEndian, uint16(1)) // エントリ数
+	binary.Write(tiff, binary.BigEndian, uint16(exifOrientationTag))
+	binary.Write(tiff, binary.BigEndian, uint16(3)) // SHORT
+	binary.Write(tiff, binary.BigEndian, uint32(1))
+	binary.Write(tiff, binary.BigEndian, orientation)
+	binary.Write(tiff, binary.BigEndian, uint16(0))
+	binary.Write(tiff, binary.BigEndian, uint32(0)) // 次の IFD なし
+	tiff.WriteString("GPS:35.6812N,139.7671E")
+
+	payload := append(append([]byte{}, exifHeader...), tiff.Bytes()...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// 左上の 8x4 だけ赤い 16x8 の JPEG に EXIF を差し込むヘルパー
+func createJPEGWithExif(t *testing.T, orientation uint16) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+	for x := 0; x < 16; x++ {
+		for y := 0; y < 8; y++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if x < 8 && y < 4 {
+				c = color.RGBA{255, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+	plain := buf.Bytes()
+
+	out := append([]byte{}, plain[:2]...)
+	out = append(out, exifSegment(orientation)...)
+	return append(out, plain[2:]...)
+}
+
+func TestNormalize_JPEG(t *testing.T) {
+	t.Run("Orientation=6 の画像は時計回りに回転され、EXIF が除去されること", func(t *testing.T) {
+		input := createJPEGWithExif(t, 6)
+
+		got, err := Normalize(input)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bytes.Contains(got, []byte("GPS:")) || bytes.Contains(got, exifHeader) {
+			t.Error("EXIF metadata should be stripped")
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(got))
+		if err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		if img.Bounds().Dx() != 8 || img.Bounds().Dy() != 16 {
+			t.Fatalf("expected 8x16 after rotation, got %dx%d", img.Bounds().Dx(), img.Bounds().Dy())
+		}
+		// 左上の赤い領域は右上に移動する
+		if r, g, _, _ := img.At(6, 2).RGBA(); r < 0xc000 || g > 0x4000 {
+			t.Errorf("expected red pixel at top-right, got r=%x g=%x", r, g)
+		}
+		if _, g, _, _ := img.At(1, 2).RGBA(); g < 0xc000 {
+			t.Errorf("expected white pixel at top-left, got g=%x", g)
+		}
+	})
+
+	t.Run("Orientation=1 の画像は再エンコードせずにメタデータのみ除去すること", func(t *testing.T) {
+		input := createJPEGWithExif(t, 1)
+
+		got, err := Normalize(input)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := append([]byte{}, input[:2]...)
+		want = append(want, input[2+len(exifSegment(1)):]...)
+		if !bytes.Equal(got, want) {
+			t.Error("only the APP1 segment should be removed")
+		}
+	})
+
+	t.Run("壊れた JPEG はエラーを返すこと", func(t *testing.T) {
+		_, err := Normalize([]byte{0xFF, 0xD8, 0x00, 0x01, 0x02, 0x03})
+		if err == nil {
+			t.Error("expected error for malformed jpeg")
+		}
+	})
+}
+
+func TestNormalize_PNG(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatalf("failed to encode png: %v", err)
+	}
+	plain := buf.Bytes()
+
+	// IEND の直前に tEXt チャンクを差し込む
+	text := []byte("Author\x00someone@example.com")
+	chunk := make([]byte, 8, 12+len(text))
+	binary.BigEndian.PutUint32(chunk[:4], uint32(len(text)))
+	copy(chunk[4:8], "tEXt")
+	chunk = append(chunk, text...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	iend := len(plain) - 12
+	input := append(append(append([]byte{}, plain[:iend]...), chunk...), plain[iend:]...)
+
+	got, err := Normalize(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("tEXt chunk should be stripped")
+	}
+}
+
+// RIFF チャンクを作成するヘルパー (奇数長のペイロードにはパディングを付ける)
+func riffChunk(fourCC string, payload []byte) []byte {
+	chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func TestNormalize_StripsWebPMetadata(t *testing.T) {
+	// 1x1 のロスレス WebP から VP8L チャンクを取り出し、EXIF と XMP を持つ拡張形式に組み直す
+	vp8l := decodeTinyWebP(t)[12:]
+	vp8x := []byte{webpFlagEXIF | webpFlagXMP, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	body := append([]byte("WEBP"), riffChunk("VP8X", vp8x)...)
+	body = append(body, vp8l...)
+	body = append(body, riffChunk("EXIF", []byte("MM\x00\x2aGPS:35.6812N"))...)
+	body = append(body, riffChunk("XMP ", []byte("<x:xmpmeta>GPS</x:xmpmeta>"))...)
+	input := append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+
+	got, err := Normalize(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(got, []byte("GPS")) || bytes.Contains(got, []byte("EXIF")) || bytes.Contains(got, []byte("XMP ")) {
+		t.Error("EXIF/XMP chunks should be stripped")
+	}
+	if flags := got[20]; flags&(webpFlagEXIF|webpFlagXMP) != 0 {
+		t.Errorf("VP8X flags should be cleared, got %#x", flags)
+	}
+	if size := binary.LittleEndian.Uint32(got[4:8]); int(size) != len(got)-8 {
+		t.Errorf("RIFF size = %d, want %d", size, len(got)-8)
+	}
+	if _, _, err := image.Decode(bytes.NewReader(got)); err != nil {
+		t.Errorf("stripped WebP should still decode: %v", err)
+	}
+}
+
+func TestNormalize_StripsGIFMetadata(t *testing.T) {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), color.Palette{color.Black, color.White})
+	buf := new(bytes.Buffer)
+	if err := gif.Encode(buf, img, nil); err != nil {
+		t.Fatalf("failed to encode gif: %v", err)
+	}
+	plain := buf.Bytes()
+
+	// ヘッダーとグローバルカラーテーブルの直後にコメント拡張と XMP のアプリケーション拡張を差し込む
+	pos := 13
+	if plain[10]&0x80 != 0 {
+		pos += 3 << ((plain[10] & 0x07) + 1)
+	}
+	comment := append([]byte{0x21, 0xFE, 11}, []byte("GPS:35.6812")...)
+	comment = append(comment, 0)
+	xmp := append([]byte{0x21, 0xFF, 11}, []byte("XMP DataXMP")...)
+	xmp = append(append(xmp, 5), []byte("GPS:1")...)
+	xmp = append(xmp, 0)
+	loop := []byte{0x21, 0xFF, 11, 'N', 'E', 'T', 'S', 'C', 'A', 'P', 'E', '2', '.', '0', 3, 1, 0, 0, 0}
+	input := append([]byte{}, plain[:pos]...)
+	input = append(append(append(append(input, comment...), xmp...), loop...), plain[pos:]...)
+
+	got, err := Normalize(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(got, []byte("GPS")) || bytes.Contains(got, []byte("XMP")) {
+		t.Error("comment and application extensions should be stripped")
+	}
+	if !bytes.Contains(got, []byte("NETSCAPE2.0")) {
+		t.Error("loop count extension should be kept")
+	}
+	if _, err := gif.Decode(bytes.NewReader(got)); err != nil {
+		t.Errorf("stripped GIF should still decode: %v", err)
+	}
+}
+
+func TestNormalize_TruncatedGIF(t *testing.T) {
+	_, err := Normalize([]byte("GIF89a\x01\x00\x01\x00\x00\x00\x00\x21\xFE\x05GPS"))
+	if !errors.Is(err, ErrMalformedImage) {
+		t.Errorf("expected ErrMalformedImage, got %v", err)
+	}
+}
+
+func TestNormalize_UnknownData(t *testing.T) {
+	input := []byte("not-an-image")
+	got, err := Normalize(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, input) {
+		t.Error("unrecognized data should be returned unchanged")
+	}
+}
+
+func TestCompress_AppliesOrientation(t *testing.T) {
+	got, err := Compress(createJPEGWithExif(t, 6), DefaultCompressionPolicy())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(got))
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != 8 || b.Dy() != 16 {
+		t.Fatalf("expected rotated 8x16, got %dx%d", b.Dx(), b.Dy())
+	}
+	// 時計回りに90度回転すると、左上の赤い領域は右上に移る
+	if r, g, _, _ := img.At(6, 1).RGBA(); r>>8 < 200 || g>>8 > 80 {
+		t.Errorf("expected red at top-right after rotation, got r=%d g=%d", r>>8, g>>8)
+	}
+	if orientation, _ := jpegOrientation(got); orientation != 1 {
+		t.Errorf("EXIF orientation should not remain in the output, got %d", orientation)
+	}
+}
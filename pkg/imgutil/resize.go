@@ -0,0 +1,65 @@
+package imgutil
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// MaxInputDimension は DefaultCompressionPolicy が参照画像を縮小する長辺の上限 (px) です。
+// ペイロードサイズを抑えるための既定値であり、API の制限値ではありません。
+const MaxInputDimension = 3072
+
+// Resize は画像を w x h に Catmull-Rom 補間で拡縮します。
+func Resize(img image.Image, w, h int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, max(w, 1), max(h, 1)))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// FitWithin はアスペクト比を維持したまま maxW x maxH に収まるよう縮小します。
+// 0 以下の値はその方向を無制限として扱います。拡大は行わず、縮小が不要な場合は元の画像を返します。
+func FitWithin(img image.Image, maxW, maxH int) image.Image {
+	w, h, ok := fitSize(img.Bounds().Dx(), img.Bounds().Dy(), maxW, maxH)
+	if !ok {
+		return img
+	}
+	return Resize(img, w, h)
+}
+
+// CoverFit はアスペクト比を維持したまま w x h 全体を覆うように拡縮し、はみ出した部分を中央で切り取ります。
+// コマ枠のように出力サイズが決まっている領域へ余白なしで画像を収める場合に使用します。
+func CoverFit(img image.Image, w, h int) image.Image {
+	w, h = max(w, 1), max(h, 1)
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	// 出力と同じアスペクト比で切り出せる最大の範囲を求める
+	cw, ch := sw, sw*h/w
+	if ch > sh {
+		cw, ch = sh*w/h, sh
+	}
+	cw, ch = max(cw, 1), max(ch, 1)
+	x0 := b.Min.X + (sw-cw)/2
+	y0 := b.Min.Y + (sh-ch)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, image.Rect(x0, y0, x0+cw, y0+ch), draw.Src, nil)
+	return dst
+}
+
+// fitSize は maxW x maxH に収まる縮小後のサイズを計算します。
+// 縮小が不要な場合は ok に false を返します。
+func fitSize(w, h, maxW, maxH int) (int, int, bool) {
+	scale := 1.0
+	if maxW > 0 && w > maxW {
+		scale = min(scale, float64(maxW)/float64(w))
+	}
+	if maxH > 0 && h > maxH {
+		scale = min(scale, float64(maxH)/float64(h))
+	}
+	if scale >= 1.0 {
+		return w, h, false
+	}
+	return max(int(float64(w)*scale), 1), max(int(float64(h)*scale), 1), true
+}
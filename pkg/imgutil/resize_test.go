@@ -0,0 +1,79 @@
+package imgutil
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFitWithin(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h         int
+		maxW, maxH   int
+		wantW, wantH int
+	}{
+		{"横長の画像は幅に合わせて縮小", 600, 300, 256, 256, 256, 128},
+		{"縦長の画像は高さに合わせて縮小", 600, 800, 256, 256, 192, 256},
+		{"上限以下の画像はそのまま", 200, 100, 256, 256, 200, 100},
+		{"0 の方向は無制限", 400, 100, 0, 50, 200, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tt.w, tt.h))
+			got := FitWithin(src, tt.maxW, tt.maxH)
+			if got.Bounds().Dx() != tt.wantW || got.Bounds().Dy() != tt.wantH {
+				t.Errorf("got %dx%d, want %dx%d", got.Bounds().Dx(), got.Bounds().Dy(), tt.wantW, tt.wantH)
+			}
+		})
+	}
+
+	t.Run("縮小が不要な場合は同じ画像を返す", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 10, 10))
+		if got := FitWithin(src, 100, 100); got != image.Image(src) {
+			t.Error("expected the original image to be returned")
+		}
+	})
+}
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 10, 20))
+	got := Resize(src, 30, 5)
+	if got.Bounds().Dx() != 30 || got.Bounds().Dy() != 5 {
+		t.Errorf("got %dx%d, want 30x5", got.Bounds().Dx(), got.Bounds().Dy())
+	}
+}
+
+func TestCoverFit(t *testing.T) {
+	// 左右 1/4 が赤、中央が青の横長画像
+	src := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x < 10 || x >= 30 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+
+	t.Run("指定サイズになり、はみ出した左右が切り取られること", func(t *testing.T) {
+		got := CoverFit(src, 10, 10)
+		if got.Bounds().Dx() != 10 || got.Bounds().Dy() != 10 {
+			t.Fatalf("got %dx%d, want 10x10", got.Bounds().Dx(), got.Bounds().Dy())
+		}
+		for _, x := range []int{0, 9} {
+			if r, _, b, _ := got.At(x, 5).RGBA(); r > b {
+				t.Errorf("pixel at x=%d should come from the blue center, got r=%d b=%d", x, r, b)
+			}
+		}
+	})
+
+	t.Run("縦長の出力でも指定サイズになること", func(t *testing.T) {
+		got := CoverFit(src, 5, 20)
+		if got.Bounds().Dx() != 5 || got.Bounds().Dy() != 20 {
+			t.Errorf("got %dx%d, want 5x20", got.Bounds().Dx(), got.Bounds().Dy())
+		}
+	})
+}